@@ -0,0 +1,275 @@
+// Command composecfg reads and edits compose files with the config package.
+//
+//	composecfg get-image [-f file] <service>
+//	composecfg set-version [-f file] [--write] <service> <version>
+//	composecfg validate [-f file] [--strict]
+//	composecfg merge [--format yaml|json] <file> <override>...
+//	composecfg export [-f file] [--format yaml|json]
+//
+// Without -f the default compose file of the current directory is used.
+// Errors are printed one per line on stderr and exit with status 1; usage
+// errors exit with status 2.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/zhangsq-ax/docker-compose/config"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const usage = `usage:
+  composecfg get-image [-f file] <service>
+  composecfg set-version [-f file] [--write] <service> <version>
+  composecfg validate [-f file] [--strict]
+  composecfg merge [--format yaml|json] <file> <override>...
+  composecfg export [-f file] [--format yaml|json]
+`
+
+var errUsage = errors.New("invalid usage")
+
+var commands = map[string]func(args []string) error{
+	"get-image":   getImage,
+	"set-version": setVersion,
+	"validate":    validate,
+	"merge":       merge,
+	"export":      export,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err := command(os.Args[2:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			fmt.Fprint(os.Stdout, usage)
+			return
+		}
+		if errors.Is(err, errUsage) {
+			fmt.Fprintf(os.Stderr, "%v\n%s", err, usage)
+			os.Exit(2)
+		}
+		printErrors(err)
+		os.Exit(1)
+	}
+}
+
+// printErrors prints every error of a MultiError on its own line.
+func printErrors(err error) {
+	var multiErr *config.MultiError
+	if errors.As(err, &multiErr) {
+		for _, e := range multiErr.Errors {
+			fmt.Fprintln(os.Stderr, e)
+		}
+		return
+	}
+	fmt.Fprintln(os.Stderr, err)
+}
+
+// parseArgs parses flags wherever they appear among args, returning the
+// positional arguments, which must number exactly n unless n is negative.
+func parseArgs(flags *flag.FlagSet, args []string, n int) ([]string, error) {
+	flags.SetOutput(io.Discard)
+	positional := []string{}
+	for {
+		if err := flags.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: %v", errUsage, err)
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if n >= 0 && len(positional) != n {
+		return nil, fmt.Errorf("%w: %s expects %d arguments, got %d", errUsage, flags.Name(), n, len(positional))
+	}
+	return positional, nil
+}
+
+func fileFlag(flags *flag.FlagSet) *string {
+	return flags.String("f", "", "compose file, defaults to the one of the current directory")
+}
+
+func composeFile(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+	return config.FindComposeFile(".")
+}
+
+func load(path string) (*config.ComposeConfig, error) {
+	path, err := composeFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return config.GetConfigFromComposeFile(path)
+}
+
+func getImage(args []string) error {
+	flags := flag.NewFlagSet("get-image", flag.ContinueOnError)
+	file := fileFlag(flags)
+	positional, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	conf, err := load(*file)
+	if err != nil {
+		return err
+	}
+	serviceConf, err := conf.LookupService(positional[0])
+	if err != nil {
+		return err
+	}
+	fmt.Println(serviceConf.Image)
+	return nil
+}
+
+// setVersion only rewrites the image scalar of YAML files, so that the rest
+// of the file keeps its order, comments and indentation.
+func setVersion(args []string) error {
+	flags := flag.NewFlagSet("set-version", flag.ContinueOnError)
+	file := fileFlag(flags)
+	write := flags.Bool("write", false, "write the change back instead of printing the result")
+	positional, err := parseArgs(flags, args, 2)
+	if err != nil {
+		return err
+	}
+	path, err := composeFile(*file)
+	if err != nil {
+		return err
+	}
+	serviceName, version := positional[0], positional[1]
+
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		conf, err := config.GetConfigFromComposeFile(path)
+		if err != nil {
+			return err
+		}
+		serviceConf, err := conf.LookupService(serviceName)
+		if err != nil {
+			return err
+		}
+		serviceConf.SetVersion(version)
+		if *write {
+			return conf.WriteToFile(path)
+		}
+		content, err := conf.ExportJSON()
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(append(content, '\n'))
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	content, err := config.SetServiceVersionYAML(data, serviceName, version)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if !*write {
+		_, err = os.Stdout.Write(content)
+		return err
+	}
+	return config.WriteFileAtomic(path, content)
+}
+
+func validate(args []string) error {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	file := fileFlag(flags)
+	strict := flags.Bool("strict", false, "also fail on unknown fields and report every decoding error")
+	if _, err := parseArgs(flags, args, 0); err != nil {
+		return err
+	}
+	if *strict {
+		path, err := composeFile(*file)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		_, err = config.ParseComposeWithOptions(content, config.ParseOptions{Strict: true})
+		return err
+	}
+	conf, err := load(*file)
+	if err != nil {
+		return err
+	}
+	return conf.Validate()
+}
+
+func merge(args []string) error {
+	flags := flag.NewFlagSet("merge", flag.ContinueOnError)
+	format := flags.String("format", "yaml", "output format, yaml or json")
+	files, err := parseArgs(flags, args, -1)
+	if err != nil {
+		return err
+	}
+	if len(files) < 2 {
+		return fmt.Errorf("%w: merge expects at least 2 files", errUsage)
+	}
+	conf, err := config.GetConfigFromComposeFile(files[0])
+	if err != nil {
+		return err
+	}
+	for _, file := range files[1:] {
+		override, err := config.GetConfigFromComposeFile(file)
+		if err != nil {
+			return err
+		}
+		conf.Merge(override)
+	}
+	return writeConfig(conf, *format)
+}
+
+func export(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	file := fileFlag(flags)
+	format := flags.String("format", "yaml", "output format, yaml or json")
+	if _, err := parseArgs(flags, args, 0); err != nil {
+		return err
+	}
+	conf, err := load(*file)
+	if err != nil {
+		return err
+	}
+	return writeConfig(conf, *format)
+}
+
+func writeConfig(conf *config.ComposeConfig, format string) error {
+	var content []byte
+	var err error
+	switch format {
+	case "yaml":
+		content, err = conf.ExportYAML()
+	case "json":
+		content, err = conf.ExportJSONIndent("", "  ")
+		content = append(content, '\n')
+	default:
+		return fmt.Errorf("%w: unknown format %q", errUsage, format)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(content)
+	return err
+}
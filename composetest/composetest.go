@@ -0,0 +1,155 @@
+//go:build composetest
+
+// Package composetest checks that the config package round-trips compose
+// files the way docker compose reads them. It is only built with the
+// composetest tag, since it needs the docker CLI with the compose plugin:
+//
+//	go test -tags composetest ./...
+package composetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/zhangsq-ax/docker-compose/config"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var regProjectNameChars = regexp.MustCompile(`[^a-z0-9_-]`)
+
+// AssertMatchesDockerCompose loads the compose file at path with the config
+// package, exports it, and has docker compose config render both the
+// original and the export. Every field where the two renderings differ is
+// reported as an error on t. The test is skipped when docker compose is not
+// available.
+//
+// Both files are rendered with the directory and project name of the
+// original, so relative paths and default names do not show up as
+// differences.
+func AssertMatchesDockerCompose(t *testing.T, path string) {
+	t.Helper()
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("docker is not installed")
+	}
+	if err := exec.Command("docker", "compose", "version").Run(); err != nil {
+		t.Skip("docker compose is not available")
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, err := config.GetConfigFromComposeFile(path)
+	if err != nil {
+		t.Fatalf("loading %s: %v", path, err)
+	}
+	exported, err := conf.ExportYAML()
+	if err != nil {
+		t.Fatalf("exporting %s: %v", path, err)
+	}
+	exportPath := filepath.Join(t.TempDir(), "compose.yml")
+	if err = os.WriteFile(exportPath, exported, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Dir(path)
+	// the default project name, as docker compose derives it from dir
+	project := regProjectNameChars.ReplaceAllString(strings.ToLower(filepath.Base(dir)), "")
+	want, err := renderConfig(path, dir, project)
+	if err != nil {
+		t.Fatalf("docker compose config of %s: %v", path, err)
+	}
+	got, err := renderConfig(exportPath, dir, project)
+	if err != nil {
+		t.Fatalf("docker compose config of the export of %s: %v\n%s", path, err, exported)
+	}
+	for _, mismatch := range diff("", want, got) {
+		t.Errorf("%s: %s", filepath.Base(path), mismatch)
+	}
+}
+
+func renderConfig(file, dir, project string) (any, error) {
+	cmd := exec.Command("docker", "compose", "-f", file, "--project-directory", dir, "-p", project, "config", "--format", "json")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	var rendered any
+	if err := json.Unmarshal(stdout.Bytes(), &rendered); err != nil {
+		return nil, err
+	}
+	return rendered, nil
+}
+
+// diff lists the fields where got differs from want, by dotted path.
+func diff(path string, want, got any) []string {
+	child := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := map[string]bool{}
+		for key := range w {
+			keys[key] = true
+		}
+		for key := range g {
+			keys[key] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+		mismatches := []string{}
+		for _, key := range sorted {
+			wv, inWant := w[key]
+			gv, inGot := g[key]
+			switch {
+			case !inGot:
+				mismatches = append(mismatches, fmt.Sprintf("%s: missing from the export, docker compose has %s", child(key), format(wv)))
+			case !inWant:
+				mismatches = append(mismatches, fmt.Sprintf("%s: only in the export, set to %s", child(key), format(gv)))
+			default:
+				mismatches = append(mismatches, diff(child(key), wv, gv)...)
+			}
+		}
+		return mismatches
+	case []any:
+		g, ok := got.([]any)
+		if !ok || len(g) != len(w) {
+			break
+		}
+		mismatches := []string{}
+		for i := range w {
+			mismatches = append(mismatches, diff(fmt.Sprintf("%s[%d]", path, i), w[i], g[i])...)
+		}
+		return mismatches
+	}
+	if reflect.DeepEqual(want, got) {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s: docker compose has %s, the export %s", path, format(want), format(got))}
+}
+
+func format(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
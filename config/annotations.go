@@ -0,0 +1,78 @@
+package config
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// ComposeAnnotationsConfig holds the OCI annotations of a service. Like the
+// environment it accepts the key=value list form and the mapping form, and
+// remembers the order keys were read in.
+type ComposeAnnotationsConfig struct {
+	Values map[string]string
+	keys   []string
+}
+
+func (a *ComposeAnnotationsConfig) Get(key string) (string, bool) {
+	value, ok := a.Values[key]
+	return value, ok
+}
+
+func (a *ComposeAnnotationsConfig) Set(key, value string) {
+	if a.Values == nil {
+		a.Values = map[string]string{}
+	}
+	a.Values[key] = value
+}
+
+func (a *ComposeAnnotationsConfig) Delete(key string) {
+	delete(a.Values, key)
+}
+
+func (a *ComposeAnnotationsConfig) Len() int {
+	return len(a.Values)
+}
+
+// Keys returns the annotation keys in file order, followed by the keys added
+// since in sorted order.
+func (a *ComposeAnnotationsConfig) Keys() []string {
+	return orderedKeys(a.keys, a.Values)
+}
+
+func (a *ComposeAnnotationsConfig) add(key, value string) {
+	if _, ok := a.Values[key]; !ok {
+		a.keys = append(a.keys, key)
+	}
+	a.Values[key] = value
+}
+
+func (a *ComposeAnnotationsConfig) UnmarshalYAML(node *yaml.Node) error {
+	a.Values, a.keys = map[string]string{}, nil
+	return decodeKeyValuesYAML(node, "annotations", ErrInvalidAnnotations, a.add, nil)
+}
+
+func (a ComposeAnnotationsConfig) MarshalYAML() (any, error) {
+	return encodeKeyValuesYAML(a.Keys(), a.Values, nil)
+}
+
+func (a *ComposeAnnotationsConfig) UnmarshalJSON(data []byte) error {
+	a.Values, a.keys = map[string]string{}, nil
+	return decodeKeyValuesJSON(data, "annotations", ErrInvalidAnnotations, a.add, nil)
+}
+
+func (a ComposeAnnotationsConfig) MarshalJSON() ([]byte, error) {
+	return encodeKeyValuesJSON(a.Keys(), a.Values, nil)
+}
+
+func (serviceConf *ComposeServiceConfig) GetAnnotation(key string) (string, bool) {
+	if serviceConf.Annotations == nil {
+		return "", false
+	}
+	return serviceConf.Annotations.Get(key)
+}
+
+func (serviceConf *ComposeServiceConfig) SetAnnotation(key, value string) {
+	if serviceConf.Annotations == nil {
+		serviceConf.Annotations = &ComposeAnnotationsConfig{}
+	}
+	serviceConf.Annotations.Set(key, value)
+}
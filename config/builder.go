@@ -0,0 +1,133 @@
+package config
+
+import (
+	"github.com/docker/cli/cli/compose/types"
+)
+
+func NewComposeConfig() *ComposeConfig {
+	return &ComposeConfig{
+		Services: &ComposeServicesConfig{},
+		Networks: map[string]*ComposeNetworkConfig{},
+		Volumes:  map[string]*ComposeVolumeConfig{},
+	}
+}
+
+// AddService registers serviceConf under its ServiceName.
+func (conf *ComposeConfig) AddService(serviceConf *ComposeServiceConfig) {
+	if conf.Services == nil {
+		conf.Services = &ComposeServicesConfig{}
+	}
+	conf.SetService(serviceConf.ServiceName, serviceConf)
+}
+
+type ServiceBuilder struct {
+	service *ComposeServiceConfig
+}
+
+func NewService(name, image string) *ServiceBuilder {
+	return &ServiceBuilder{
+		service: &ComposeServiceConfig{
+			ServiceName: name,
+			Image:       image,
+		},
+	}
+}
+
+func (b *ServiceBuilder) WithEnv(key, value string) *ServiceBuilder {
+	b.service.SetEnv(key, value)
+	return b
+}
+
+func (b *ServiceBuilder) WithPort(spec string) *ServiceBuilder {
+	b.service.Ports = append(b.service.Ports, ComposeServicePortConfig{Spec: spec})
+	return b
+}
+
+func (b *ServiceBuilder) WithVolume(spec string) *ServiceBuilder {
+	b.service.Volumes = append(b.service.Volumes, ComposeServiceVolumeConfig{Spec: spec})
+	return b
+}
+
+func (b *ServiceBuilder) WithLabel(key, value string) *ServiceBuilder {
+	if b.service.Labels == nil {
+		b.service.Labels = &types.Labels{}
+	}
+	(*b.service.Labels)[key] = value
+	return b
+}
+
+func (b *ServiceBuilder) WithRestart(restart string) *ServiceBuilder {
+	b.service.Restart = restart
+	return b
+}
+
+func (b *ServiceBuilder) WithHealthcheck(healthcheck *ComposeHealthcheckConfig) *ServiceBuilder {
+	b.service.Healthcheck = healthcheck
+	return b
+}
+
+func (b *ServiceBuilder) DependsOn(services ...string) *ServiceBuilder {
+	for _, service := range services {
+		b.DependsOnCondition(service, "")
+	}
+	return b
+}
+
+func (b *ServiceBuilder) DependsOnCondition(service, condition string) *ServiceBuilder {
+	if b.service.DependsOn == nil {
+		b.service.DependsOn = &ComposeDependsOnConfig{}
+	}
+	(*b.service.DependsOn)[service] = &ComposeDependentConfig{
+		ServiceName: service,
+		Condition:   condition,
+	}
+	return b
+}
+
+func (b *ServiceBuilder) Build() *ComposeServiceConfig {
+	return b.service
+}
+
+// EnsureNetwork returns the network called name, declaring it with cfg (or an
+// empty definition when cfg is nil) if it does not exist yet.
+func (conf *ComposeConfig) EnsureNetwork(name string, cfg *ComposeNetworkConfig) *ComposeNetworkConfig {
+	if conf.Networks == nil {
+		conf.Networks = map[string]*ComposeNetworkConfig{}
+	}
+	if networkConf := conf.Networks[name]; networkConf != nil {
+		return networkConf
+	}
+	if cfg == nil {
+		cfg = &ComposeNetworkConfig{}
+	}
+	conf.Networks[name] = cfg
+	return cfg
+}
+
+// EnsureVolume returns the volume called name, declaring it with cfg (or an
+// empty definition when cfg is nil) if it does not exist yet.
+func (conf *ComposeConfig) EnsureVolume(name string, cfg *ComposeVolumeConfig) *ComposeVolumeConfig {
+	if conf.Volumes == nil {
+		conf.Volumes = map[string]*ComposeVolumeConfig{}
+	}
+	if volumeConf := conf.Volumes[name]; volumeConf != nil {
+		return volumeConf
+	}
+	if cfg == nil {
+		cfg = &ComposeVolumeConfig{}
+	}
+	conf.Volumes[name] = cfg
+	return cfg
+}
+
+// UseNetwork attaches the service to the network once, declaring the network
+// on parent when parent is not nil.
+func (serviceConf *ComposeServiceConfig) UseNetwork(name string, parent *ComposeConfig) {
+	if parent != nil {
+		parent.EnsureNetwork(name, nil)
+	}
+	if serviceConf.Networks.Get(name) != nil {
+		return
+	}
+	serviceConf.Networks = append(serviceConf.Networks, &ComposeServiceNetworkConfig{Name: name})
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"gopkg.in/yaml.v3"
+	"strings"
+)
+
+// ComposeCommandConfig is a command or entrypoint. The string form is split
+// into words the way a shell would, without expanding anything, and both
+// forms are exported as a list like docker compose config does. An empty
+// entrypoint is not nil: it clears the one of the image.
+type ComposeCommandConfig []string
+
+func (c *ComposeCommandConfig) UnmarshalYAML(node *yaml.Node) error {
+	return c.decode(node, "command")
+}
+
+// ComposeEntrypointConfig is ComposeCommandConfig under another name, so that
+// parse errors name the right field.
+type ComposeEntrypointConfig ComposeCommandConfig
+
+func (e *ComposeEntrypointConfig) UnmarshalYAML(node *yaml.Node) error {
+	return (*ComposeCommandConfig)(e).decode(node, "entrypoint")
+}
+
+func (e *ComposeEntrypointConfig) UnmarshalJSON(data []byte) error {
+	return (*ComposeCommandConfig)(e).UnmarshalJSON(data)
+}
+
+func (c *ComposeCommandConfig) decode(node *yaml.Node, field string) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		words, err := splitShellWords(node.Value)
+		if err != nil {
+			return newParseError(node, field, "%v", err)
+		}
+		*c = words
+		return nil
+	case yaml.SequenceNode:
+		words := []string{}
+		if err := node.Decode(&words); err != nil {
+			return newParseError(node, field, "expected a list of strings")
+		}
+		*c = words
+		return nil
+	}
+	return newParseError(node, field, "expected a string or a list of strings")
+}
+
+func (c *ComposeCommandConfig) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := jsoniter.Unmarshal(data, &s); err == nil {
+		words, err := splitShellWords(s)
+		if err != nil {
+			return fmt.Errorf("invalid command format: %w", err)
+		}
+		*c = words
+		return nil
+	}
+	words := []string{}
+	if err := jsoniter.Unmarshal(data, &words); err != nil {
+		return fmt.Errorf("invalid command format: %w", err)
+	}
+	*c = words
+	return nil
+}
+
+// splitShellWords splits s on unquoted blanks, removing single quotes, double
+// quotes and backslash escapes as a POSIX shell would.
+func splitShellWords(s string) ([]string, error) {
+	words := []string{}
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '\\':
+			inWord = true
+			if i+1 < len(s) {
+				i++
+				word.WriteByte(s[i])
+			}
+		case c == '\'':
+			inWord = true
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote in %q", s)
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+		case c == '"':
+			inWord = true
+			closed := false
+			for i++; i < len(s); i++ {
+				if s[i] == '"' {
+					closed = true
+					break
+				}
+				// inside double quotes a backslash only escapes these
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`", s[i+1]) >= 0 {
+					i++
+				}
+				word.WriteByte(s[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated double quote in %q", s)
+			}
+		default:
+			inWord = true
+			word.WriteByte(c)
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
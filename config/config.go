@@ -1,30 +1,190 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"github.com/docker/cli/cli/compose/types"
 	jsoniter "github.com/json-iterator/go"
 	"gopkg.in/yaml.v3"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"sort"
+	"strconv"
+	"strings"
 )
 
 var (
 	regServiceImage = regexp.MustCompile(`^(.+?)(:([^/]+?))?$`)
-	regEnv          = regexp.MustCompile(`^(.+?)=(.+?)$`)
+	regEnv          = regexp.MustCompile(`(?s)^([^=]+)=(.*)$`)
 )
 
 type ComposeNetworkConfig struct {
-	Name     string `yaml:"name,omitempty" json:"name,omitempty"`
-	Driver   string `yaml:"driver,omitempty" json:"driver,omitempty"`
-	External bool   `yaml:"external,omitempty" json:"external,omitempty"`
+	Name       string                 `yaml:"name,omitempty" json:"name,omitempty"`
+	Driver     string                 `yaml:"driver,omitempty" json:"driver,omitempty"`
+	DriverOpts map[string]string      `yaml:"driver_opts,omitempty" json:"driver_opts,omitempty"`
+	External   *ComposeExternalConfig `yaml:"external,omitempty" json:"external,omitempty"`
+	Labels     *types.Labels          `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Ipam       *ComposeIPAMConfig     `yaml:"ipam,omitempty" json:"ipam,omitempty"`
+}
+
+type ComposeIPAMConfig struct {
+	Driver  string                   `yaml:"driver,omitempty" json:"driver,omitempty"`
+	Config  []*ComposeIPAMPoolConfig `yaml:"config,omitempty" json:"config,omitempty"`
+	Options map[string]string        `yaml:"options,omitempty" json:"options,omitempty"`
+}
+
+type ComposeIPAMPoolConfig struct {
+	Subnet       string            `yaml:"subnet,omitempty" json:"subnet,omitempty"`
+	IPRange      string            `yaml:"ip_range,omitempty" json:"ip_range,omitempty"`
+	Gateway      string            `yaml:"gateway,omitempty" json:"gateway,omitempty"`
+	AuxAddresses map[string]string `yaml:"aux_addresses,omitempty" json:"aux_addresses,omitempty"`
+}
+
+type ComposeVolumeConfig struct {
+	Name       string                 `yaml:"name,omitempty" json:"name,omitempty"`
+	Driver     string                 `yaml:"driver,omitempty" json:"driver,omitempty"`
+	DriverOpts map[string]string      `yaml:"driver_opts,omitempty" json:"driver_opts,omitempty"`
+	External   *ComposeExternalConfig `yaml:"external,omitempty" json:"external,omitempty"`
+	Labels     *types.Labels          `yaml:"labels,omitempty" json:"labels,omitempty"`
+}
+
+// ComposeExternalConfig holds both the boolean form of external and the legacy
+// {name: ...} mapping form, marshaling back to whichever form carries the data.
+type ComposeExternalConfig struct {
+	External bool
+	Name     string
+}
+
+func (e *ComposeExternalConfig) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if err := node.Decode(&e.External); err != nil {
+			return newParseError(node, "external", "invalid external format")
+		}
+		return nil
+	case yaml.MappingNode:
+		var external struct {
+			Name string `yaml:"name"`
+		}
+		if err := node.Decode(&external); err != nil {
+			return err
+		}
+		e.External, e.Name = true, external.Name
+		return nil
+	}
+	return newParseError(node, "external", "invalid external format")
+}
+
+func (e *ComposeExternalConfig) MarshalYAML() (any, error) {
+	if e.Name == "" {
+		return e.External, nil
+	}
+	return map[string]string{"name": e.Name}, nil
+}
+
+func (e *ComposeExternalConfig) UnmarshalJSON(data []byte) error {
+	var external struct {
+		Name string `json:"name"`
+	}
+	if err := jsoniter.Unmarshal(data, &external); err == nil {
+		e.External, e.Name = true, external.Name
+		return nil
+	}
+	e.Name = ""
+	return jsoniter.Unmarshal(data, &e.External)
+}
+
+func (e *ComposeExternalConfig) MarshalJSON() ([]byte, error) {
+	value, _ := e.MarshalYAML()
+	return jsoniter.Marshal(value)
+}
+
+func (networkConf *ComposeNetworkConfig) IsExternal() bool {
+	return networkConf.External != nil && networkConf.External.External
+}
+
+// ExternalName returns the name of the pre-existing network, or "" when the
+// network is not external or takes its name from its key in the file.
+func (networkConf *ComposeNetworkConfig) ExternalName() string {
+	if !networkConf.IsExternal() {
+		return ""
+	}
+	if networkConf.External.Name != "" {
+		return networkConf.External.Name
+	}
+	return networkConf.Name
+}
+
+func (volumeConf *ComposeVolumeConfig) IsExternal() bool {
+	return volumeConf.External != nil && volumeConf.External.External
+}
+
+// ExternalName returns the name of the pre-existing volume, or "" when the
+// volume is not external or takes its name from its key in the file.
+func (volumeConf *ComposeVolumeConfig) ExternalName() string {
+	if !volumeConf.IsExternal() {
+		return ""
+	}
+	if volumeConf.External.Name != "" {
+		return volumeConf.External.Name
+	}
+	return volumeConf.Name
+}
+
+type ComposeSecretConfig struct {
+	Name           string            `yaml:"name,omitempty" json:"name,omitempty"`
+	File           string            `yaml:"file,omitempty" json:"file,omitempty"`
+	Environment    string            `yaml:"environment,omitempty" json:"environment,omitempty"`
+	External       bool              `yaml:"external,omitempty" json:"external,omitempty"`
+	Labels         *types.Labels     `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Driver         string            `yaml:"driver,omitempty" json:"driver,omitempty"`
+	DriverOpts     map[string]string `yaml:"driver_opts,omitempty" json:"driver_opts,omitempty"`
+	TemplateDriver string            `yaml:"template_driver,omitempty" json:"template_driver,omitempty"`
+}
+
+type ComposeConfigObjConfig struct {
+	Name        string        `yaml:"name,omitempty" json:"name,omitempty"`
+	File        string        `yaml:"file,omitempty" json:"file,omitempty"`
+	Environment string        `yaml:"environment,omitempty" json:"environment,omitempty"`
+	Content     string        `yaml:"content,omitempty" json:"content,omitempty"`
+	External    bool          `yaml:"external,omitempty" json:"external,omitempty"`
+	Labels      *types.Labels `yaml:"labels,omitempty" json:"labels,omitempty"`
+}
+
+type ComposeBuildConfig struct {
+	Context    string            `json:"context,omitempty" yaml:"context,omitempty"`
+	Dockerfile string            `json:"dockerfile,omitempty" yaml:"dockerfile,omitempty"`
+	Args       map[string]string `json:"args,omitempty" yaml:"args,omitempty"`
+	Target     string            `json:"target,omitempty" yaml:"target,omitempty"`
+}
+
+func (b *ComposeBuildConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		b.Context = node.Value
+		return nil
+	}
+	if node.Kind == yaml.MappingNode {
+		type plain ComposeBuildConfig
+		return node.Decode((*plain)(b))
+	}
+	return newParseError(node, "build", "invalid build format")
+}
+
+func (b *ComposeBuildConfig) MarshalYAML() (any, error) {
+	if b.Dockerfile == "" && len(b.Args) == 0 && b.Target == "" {
+		return b.Context, nil
+	}
+	type plain ComposeBuildConfig
+	return (*plain)(b), nil
 }
 
 type ComposeDependentConfig struct {
-	ServiceName string `yaml:"-"`
-	Condition   string `json:"condition" yaml:"condition,omitempty"`
+	ServiceName string `json:"-" yaml:"-"`
+	Condition   string `json:"condition,omitempty" yaml:"condition,omitempty"`
 }
 
 type ComposeHealthCheckTest []string
@@ -35,6 +195,7 @@ func (t ComposeHealthCheckTest) MarshalYAML() (any, error) {
 		nodes = append(nodes, &yaml.Node{
 			Value: item,
 			Kind:  yaml.ScalarNode,
+			Tag:   "!!str",
 		})
 	}
 	return &yaml.Node{
@@ -84,7 +245,7 @@ func (d *ComposeDependsOnConfig) UnmarshalYAML(node *yaml.Node) error {
 		return nil
 	}
 
-	return fmt.Errorf("invalid depends_on format")
+	return newParseError(node, "depends_on", "invalid depends_on format").wrapping(ErrInvalidDependsOn)
 }
 
 func (d *ComposeDependsOnConfig) MarshalYAML() (any, error) {
@@ -97,11 +258,7 @@ func (d *ComposeDependsOnConfig) MarshalYAML() (any, error) {
 	}
 
 	if allSimple {
-		var services []string
-		for service := range *d {
-			services = append(services, service)
-		}
-		return services, nil
+		return sortedKeys(*d), nil
 	}
 
 	result := map[string]any{}
@@ -117,47 +274,738 @@ func (d *ComposeDependsOnConfig) MarshalYAML() (any, error) {
 	return result, nil
 }
 
-type ComposeEnvironmentConfig map[string]string
+func (d *ComposeDependsOnConfig) UnmarshalJSON(data []byte) error {
+	var services []string
+	if err := jsoniter.Unmarshal(data, &services); err == nil {
+		*d = make(map[string]*ComposeDependentConfig, len(services))
+		for _, service := range services {
+			(*d)[service] = &ComposeDependentConfig{ServiceName: service}
+		}
+		return nil
+	}
+	var deps map[string]*ComposeDependentConfig
+	if err := jsoniter.Unmarshal(data, &deps); err != nil {
+		return fmt.Errorf("%w format: %w", ErrInvalidDependsOn, err)
+	}
+	*d = make(map[string]*ComposeDependentConfig, len(deps))
+	for service, dep := range deps {
+		if dep == nil {
+			dep = &ComposeDependentConfig{}
+		}
+		dep.ServiceName = service
+		(*d)[service] = dep
+	}
+	return nil
+}
+
+func (d *ComposeDependsOnConfig) MarshalJSON() ([]byte, error) {
+	value, _ := d.MarshalYAML()
+	return sortedJSON.Marshal(value)
+}
+
+// ComposeDeviceCount is the count of a device request: a number or "all".
+type ComposeDeviceCount string
+
+func (c ComposeDeviceCount) MarshalYAML() (any, error) {
+	if n, err := strconv.ParseInt(string(c), 10, 64); err == nil {
+		return n, nil
+	}
+	return string(c), nil
+}
+
+func (c *ComposeDeviceCount) UnmarshalJSON(data []byte) error {
+	var n int64
+	if err := jsoniter.Unmarshal(data, &n); err == nil {
+		*c = ComposeDeviceCount(strconv.FormatInt(n, 10))
+		return nil
+	}
+	return jsoniter.Unmarshal(data, (*string)(c))
+}
+
+func (c ComposeDeviceCount) MarshalJSON() ([]byte, error) {
+	value, _ := c.MarshalYAML()
+	return jsoniter.Marshal(value)
+}
+
+// ComposeBlkioRate is a throttling rate: a number, or for the bps limits a
+// byte size such as "12mb".
+type ComposeBlkioRate string
+
+func (r ComposeBlkioRate) MarshalYAML() (any, error) {
+	if n, err := strconv.ParseInt(string(r), 10, 64); err == nil {
+		return n, nil
+	}
+	return string(r), nil
+}
 
-func (e *ComposeEnvironmentConfig) UnmarshalYAML(node *yaml.Node) error {
+func (r *ComposeBlkioRate) UnmarshalJSON(data []byte) error {
+	var n int64
+	if err := jsoniter.Unmarshal(data, &n); err == nil {
+		*r = ComposeBlkioRate(strconv.FormatInt(n, 10))
+		return nil
+	}
+	return jsoniter.Unmarshal(data, (*string)(r))
+}
+
+func (r ComposeBlkioRate) MarshalJSON() ([]byte, error) {
+	value, _ := r.MarshalYAML()
+	return jsoniter.Marshal(value)
+}
+
+type ComposeBlkioWeightDeviceConfig struct {
+	Path   string `json:"path" yaml:"path"`
+	Weight uint16 `json:"weight" yaml:"weight"`
+}
+
+type ComposeBlkioThrottleDeviceConfig struct {
+	Path string           `json:"path" yaml:"path"`
+	Rate ComposeBlkioRate `json:"rate" yaml:"rate"`
+}
+
+type ComposeBlkioConfig struct {
+	Weight          uint16                              `json:"weight,omitempty" yaml:"weight,omitempty"`
+	WeightDevice    []*ComposeBlkioWeightDeviceConfig   `json:"weight_device,omitempty" yaml:"weight_device,omitempty"`
+	DeviceReadBps   []*ComposeBlkioThrottleDeviceConfig `json:"device_read_bps,omitempty" yaml:"device_read_bps,omitempty"`
+	DeviceReadIOps  []*ComposeBlkioThrottleDeviceConfig `json:"device_read_iops,omitempty" yaml:"device_read_iops,omitempty"`
+	DeviceWriteBps  []*ComposeBlkioThrottleDeviceConfig `json:"device_write_bps,omitempty" yaml:"device_write_bps,omitempty"`
+	DeviceWriteIOps []*ComposeBlkioThrottleDeviceConfig `json:"device_write_iops,omitempty" yaml:"device_write_iops,omitempty"`
+}
+
+type ComposeDeviceRequestConfig struct {
+	Driver       string             `json:"driver,omitempty" yaml:"driver,omitempty"`
+	Count        ComposeDeviceCount `json:"count,omitempty" yaml:"count,omitempty"`
+	DeviceIDs    []string           `json:"device_ids,omitempty" yaml:"device_ids,omitempty"`
+	Capabilities []string           `json:"capabilities,omitempty" yaml:"capabilities,omitempty"`
+	Options      map[string]string  `json:"options,omitempty" yaml:"options,omitempty"`
+}
+
+type ComposeResourceConfig struct {
+	Cpus    string                        `json:"cpus,omitempty" yaml:"cpus,omitempty"`
+	Memory  string                        `json:"memory,omitempty" yaml:"memory,omitempty"`
+	Devices []*ComposeDeviceRequestConfig `json:"devices,omitempty" yaml:"devices,omitempty"`
+}
+
+func (resourceConf *ComposeResourceConfig) MemoryBytes() (int64, error) {
+	return ParseBytes(resourceConf.Memory)
+}
+
+func (resourceConf *ComposeResourceConfig) CPUs() (float64, error) {
+	return ParseCPUs(resourceConf.Cpus)
+}
+
+type ComposeResourcesConfig struct {
+	Limits       *ComposeResourceConfig `json:"limits,omitempty" yaml:"limits,omitempty"`
+	Reservations *ComposeResourceConfig `json:"reservations,omitempty" yaml:"reservations,omitempty"`
+}
+
+type ComposeRestartPolicyConfig struct {
+	Condition   string  `json:"condition,omitempty" yaml:"condition,omitempty"`
+	Delay       string  `json:"delay,omitempty" yaml:"delay,omitempty"`
+	MaxAttempts *uint64 `json:"max_attempts,omitempty" yaml:"max_attempts,omitempty"`
+	Window      string  `json:"window,omitempty" yaml:"window,omitempty"`
+}
+
+type ComposeDeployConfig struct {
+	Mode          string                      `json:"mode,omitempty" yaml:"mode,omitempty"`
+	Replicas      *uint64                     `json:"replicas,omitempty" yaml:"replicas,omitempty"`
+	Labels        *types.Labels               `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Resources     *ComposeResourcesConfig     `json:"resources,omitempty" yaml:"resources,omitempty"`
+	RestartPolicy *ComposeRestartPolicyConfig `json:"restart_policy,omitempty" yaml:"restart_policy,omitempty"`
+}
+
+type PullPolicy string
+
+const (
+	PullPolicyAlways  PullPolicy = "always"
+	PullPolicyNever   PullPolicy = "never"
+	PullPolicyMissing PullPolicy = "missing"
+	PullPolicyBuild   PullPolicy = "build"
+)
+
+// ComposeEnvFileEntry is one env_file entry. The short form is the bare path.
+type ComposeEnvFileEntry struct {
+	Path string `json:"path" yaml:"path"`
+	// Required is nil when left to the default, true.
+	Required *bool `json:"required,omitempty" yaml:"required,omitempty"`
+}
+
+// IsRequired reports whether a missing file is an error.
+func (entry ComposeEnvFileEntry) IsRequired() bool {
+	return entry.Required == nil || *entry.Required
+}
+
+func (entry *ComposeEnvFileEntry) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		entry.Path = node.Value
+		return nil
+	case yaml.MappingNode:
+		type plain ComposeEnvFileEntry
+		if err := node.Decode((*plain)(entry)); err != nil {
+			return err
+		}
+		if entry.Path == "" {
+			return newParseError(node, "env_file", "missing path")
+		}
+		return nil
+	}
+	return newParseError(node, "env_file", "invalid env_file format")
+}
+
+func (entry ComposeEnvFileEntry) MarshalYAML() (any, error) {
+	if entry.Required == nil {
+		return entry.Path, nil
+	}
+	type plain ComposeEnvFileEntry
+	return plain(entry), nil
+}
+
+func (entry *ComposeEnvFileEntry) UnmarshalJSON(data []byte) error {
+	if err := jsoniter.Unmarshal(data, &entry.Path); err == nil {
+		return nil
+	}
+	type plain ComposeEnvFileEntry
+	if err := jsoniter.Unmarshal(data, (*plain)(entry)); err != nil {
+		return fmt.Errorf("invalid env_file format: %w", err)
+	}
+	if entry.Path == "" {
+		return fmt.Errorf("invalid env_file format: missing path")
+	}
+	return nil
+}
+
+func (entry ComposeEnvFileEntry) MarshalJSON() ([]byte, error) {
+	if entry.Required == nil {
+		return jsoniter.Marshal(entry.Path)
+	}
+	type plain ComposeEnvFileEntry
+	return jsoniter.Marshal(plain(entry))
+}
+
+type ComposeEnvFileConfig []ComposeEnvFileEntry
+
+func (f *ComposeEnvFileConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*f = ComposeEnvFileConfig{{Path: node.Value}}
+		return nil
+	}
 	if node.Kind == yaml.SequenceNode {
-		*e = make(map[string]string)
-		for _, item := range node.Content {
-			match := regEnv.FindAllStringSubmatch(item.Value, -1)
-			if len(match) > 0 {
-				(*e)[match[0][1]] = match[0][2]
-			} else {
-				return fmt.Errorf("invalid environment format: %s", item.Value)
+		*f = make(ComposeEnvFileConfig, len(node.Content))
+		for i, item := range node.Content {
+			if err := item.Decode(&(*f)[i]); err != nil {
+				return err
 			}
 		}
 		return nil
 	}
-	if node.Kind == yaml.MappingNode {
-		*e = make(map[string]string)
+	return newParseError(node, "env_file", "invalid env_file format")
+}
+
+func (f *ComposeEnvFileConfig) UnmarshalJSON(data []byte) error {
+	var path string
+	if err := jsoniter.Unmarshal(data, &path); err == nil {
+		*f = ComposeEnvFileConfig{{Path: path}}
+		return nil
+	}
+	var entries []ComposeEnvFileEntry
+	if err := jsoniter.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("invalid env_file format: %w", err)
+	}
+	*f = entries
+	return nil
+}
+
+// Paths returns the path of every entry.
+func (f ComposeEnvFileConfig) Paths() []string {
+	paths := make([]string, 0, len(f))
+	for _, entry := range f {
+		paths = append(paths, entry.Path)
+	}
+	return paths
+}
+
+type ComposeServiceSecretConfig struct {
+	Source string  `json:"source" yaml:"source"`
+	Target string  `json:"target,omitempty" yaml:"target,omitempty"`
+	UID    string  `json:"uid,omitempty" yaml:"uid,omitempty"`
+	GID    string  `json:"gid,omitempty" yaml:"gid,omitempty"`
+	Mode   *uint32 `json:"mode,omitempty" yaml:"mode,omitempty"`
+}
+
+type ComposeServiceSecretsConfig []*ComposeServiceSecretConfig
+
+func (s *ComposeServiceSecretsConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.SequenceNode {
+		return newParseError(node, "secrets", "invalid secrets format")
+	}
+	*s = make([]*ComposeServiceSecretConfig, 0, len(node.Content))
+	for _, item := range node.Content {
+		secret := &ComposeServiceSecretConfig{}
+		switch item.Kind {
+		case yaml.ScalarNode:
+			secret.Source = item.Value
+		case yaml.MappingNode:
+			if err := item.Decode(secret); err != nil {
+				return err
+			}
+		default:
+			return newParseError(item, "secrets", "invalid secrets format")
+		}
+		*s = append(*s, secret)
+	}
+	return nil
+}
+
+func (s ComposeServiceSecretsConfig) MarshalYAML() (any, error) {
+	result := make([]any, 0, len(s))
+	for _, secret := range s {
+		if secret.Target == "" && secret.UID == "" && secret.GID == "" && secret.Mode == nil {
+			result = append(result, secret.Source)
+		} else {
+			result = append(result, secret)
+		}
+	}
+	return result, nil
+}
+
+type ComposeServiceNetworkConfig struct {
+	Name        string   `json:"-" yaml:"-"`
+	Aliases     []string `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+	Ipv4Address string   `json:"ipv4_address,omitempty" yaml:"ipv4_address,omitempty"`
+	Ipv6Address string   `json:"ipv6_address,omitempty" yaml:"ipv6_address,omitempty"`
+	Priority    *int     `json:"priority,omitempty" yaml:"priority,omitempty"`
+}
+
+func (n *ComposeServiceNetworkConfig) isShort() bool {
+	return len(n.Aliases) == 0 && n.Ipv4Address == "" && n.Ipv6Address == "" && n.Priority == nil
+}
+
+// ComposeServiceNetworksConfig accepts both the list of network names and the
+// mapping of names to attachment options.
+type ComposeServiceNetworksConfig []*ComposeServiceNetworkConfig
+
+func (s *ComposeServiceNetworksConfig) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		*s = make([]*ComposeServiceNetworkConfig, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return newParseError(item, "networks", "invalid networks format")
+			}
+			*s = append(*s, &ComposeServiceNetworkConfig{Name: item.Value})
+		}
+		return nil
+	case yaml.MappingNode:
+		*s = make([]*ComposeServiceNetworkConfig, 0, len(node.Content)/2)
 		for i := 0; i < len(node.Content); i += 2 {
-			(*e)[node.Content[i].Value] = node.Content[i+1].Value
+			network := &ComposeServiceNetworkConfig{}
+			if value := node.Content[i+1]; value.Tag != "!!null" {
+				if err := value.Decode(network); err != nil {
+					return err
+				}
+			}
+			network.Name = node.Content[i].Value
+			*s = append(*s, network)
+		}
+		return nil
+	}
+	return newParseError(node, "networks", "invalid networks format")
+}
+
+func (s ComposeServiceNetworksConfig) MarshalYAML() (any, error) {
+	short := true
+	for _, network := range s {
+		short = short && network.isShort()
+	}
+	if short {
+		return s.Names(), nil
+	}
+	result := make(map[string]*ComposeServiceNetworkConfig, len(s))
+	for _, network := range s {
+		if network.isShort() {
+			result[network.Name] = nil
+		} else {
+			result[network.Name] = network
+		}
+	}
+	return result, nil
+}
+
+func (s *ComposeServiceNetworksConfig) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := jsoniter.Unmarshal(data, &names); err == nil {
+		*s = make([]*ComposeServiceNetworkConfig, 0, len(names))
+		for _, name := range names {
+			*s = append(*s, &ComposeServiceNetworkConfig{Name: name})
 		}
 		return nil
 	}
-	return fmt.Errorf("invalid environment format")
+	var networks map[string]*ComposeServiceNetworkConfig
+	if err := jsoniter.Unmarshal(data, &networks); err != nil {
+		return err
+	}
+	*s = make([]*ComposeServiceNetworkConfig, 0, len(networks))
+	for _, name := range sortedKeys(networks) {
+		network := networks[name]
+		if network == nil {
+			network = &ComposeServiceNetworkConfig{}
+		}
+		network.Name = name
+		*s = append(*s, network)
+	}
+	return nil
+}
+
+func (s ComposeServiceNetworksConfig) MarshalJSON() ([]byte, error) {
+	value, _ := s.MarshalYAML()
+	return sortedJSON.Marshal(value)
+}
+
+func (s ComposeServiceNetworksConfig) Names() []string {
+	names := make([]string, 0, len(s))
+	for _, network := range s {
+		names = append(names, network.Name)
+	}
+	return names
+}
+
+func (s ComposeServiceNetworksConfig) Get(name string) *ComposeServiceNetworkConfig {
+	for _, network := range s {
+		if network.Name == name {
+			return network
+		}
+	}
+	return nil
 }
 
 type ComposeServiceConfig struct {
-	ServiceName   string                    `json:"-" yaml:"-"`
-	Image         string                    `json:"image" yaml:"image"`
-	ContainerName string                    `json:"container_name,omitempty" yaml:"container_name,omitempty"`
-	Hostname      string                    `json:"hostname,omitempty" yaml:"hostname,omitempty"`
-	Restart       string                    `json:"restart,omitempty" yaml:"restart,omitempty"`
-	Environment   *ComposeEnvironmentConfig `json:"environment,omitempty" yaml:"environment,omitempty"`
-	Logging       *types.LoggingConfig      `json:"logging,omitempty" yaml:"logging,omitempty"`
-	Networks      []string                  `json:"networks,omitempty" yaml:"networks,omitempty"`
-	Ports         []string                  `json:"ports,omitempty" yaml:"ports,omitempty"`
-	Volumes       []string                  `json:"volumes,omitempty" yaml:"volumes,omitempty"`
-	Labels        *types.Labels             `json:"labels,omitempty" yaml:"labels,omitempty"`
-	DependsOn     *ComposeDependsOnConfig   `json:"depends_on,omitempty" yaml:"depends_on,omitempty"`
-	Healthcheck   *ComposeHealthcheckConfig `json:"healthcheck,omitempty" yaml:"healthcheck,omitempty"`
-	Privileged    bool                      `json:"privileged,omitempty" yaml:"privileged,omitempty"`
-	SecurityOpt   []string                  `json:"security_opt,omitempty" yaml:"security_opt,omitempty"`
+	ServiceName    string                       `json:"-" yaml:"-"`
+	Image          string                       `json:"image" yaml:"image"`
+	ContainerName  string                       `json:"container_name,omitempty" yaml:"container_name,omitempty"`
+	Hostname       string                       `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+	Restart        string                       `json:"restart,omitempty" yaml:"restart,omitempty"`
+	Environment    *ComposeEnvironmentConfig    `json:"environment,omitempty" yaml:"environment,omitempty"`
+	Logging        *ComposeLoggingConfig        `json:"logging,omitempty" yaml:"logging,omitempty"`
+	Networks       ComposeServiceNetworksConfig `json:"networks,omitempty" yaml:"networks,omitempty"`
+	Ports          ComposeServicePortsConfig    `json:"ports,omitempty" yaml:"ports,omitempty"`
+	Volumes        ComposeServiceVolumesConfig  `json:"volumes,omitempty" yaml:"volumes,omitempty"`
+	Labels         *types.Labels                `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Annotations    *ComposeAnnotationsConfig    `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+	DependsOn      *ComposeDependsOnConfig      `json:"depends_on,omitempty" yaml:"depends_on,omitempty"`
+	Healthcheck    *ComposeHealthcheckConfig    `json:"healthcheck,omitempty" yaml:"healthcheck,omitempty"`
+	Privileged     *bool                        `json:"privileged,omitempty" yaml:"privileged,omitempty"`
+	SecurityOpt    []string                     `json:"security_opt,omitempty" yaml:"security_opt,omitempty"`
+	Secrets        ComposeServiceSecretsConfig  `json:"secrets,omitempty" yaml:"secrets,omitempty"`
+	PullPolicy     string                       `json:"pull_policy,omitempty" yaml:"pull_policy,omitempty"`
+	EnvFile        ComposeEnvFileConfig         `json:"env_file,omitempty" yaml:"env_file,omitempty"`
+	ShmSize        string                       `json:"shm_size,omitempty" yaml:"shm_size,omitempty"`
+	MemLimit       string                       `json:"mem_limit,omitempty" yaml:"mem_limit,omitempty"`
+	MemReservation string                       `json:"mem_reservation,omitempty" yaml:"mem_reservation,omitempty"`
+	CPUCount       *int64                       `json:"cpu_count,omitempty" yaml:"cpu_count,omitempty"`
+	CPUPercent     *float32                     `json:"cpu_percent,omitempty" yaml:"cpu_percent,omitempty"`
+	Deploy         *ComposeDeployConfig         `json:"deploy,omitempty" yaml:"deploy,omitempty"`
+	Scale          *uint64                      `json:"scale,omitempty" yaml:"scale,omitempty"`
+	Isolation      string                       `json:"isolation,omitempty" yaml:"isolation,omitempty"`
+	UsernsMode     string                       `json:"userns_mode,omitempty" yaml:"userns_mode,omitempty"`
+	Build          *ComposeBuildConfig          `json:"build,omitempty" yaml:"build,omitempty"`
+	DomainName     string                       `json:"domainname,omitempty" yaml:"domainname,omitempty"`
+	MacAddress     string                       `json:"mac_address,omitempty" yaml:"mac_address,omitempty"`
+	OOMKillDisable *bool                        `json:"oom_kill_disable,omitempty" yaml:"oom_kill_disable,omitempty"`
+	OOMScoreAdj    *int                         `json:"oom_score_adj,omitempty" yaml:"oom_score_adj,omitempty"`
+	NetworkMode    string                       `json:"network_mode,omitempty" yaml:"network_mode,omitempty"`
+	Pid            string                       `json:"pid,omitempty" yaml:"pid,omitempty"`
+	Ipc            string                       `json:"ipc,omitempty" yaml:"ipc,omitempty"`
+	ReadOnly       *bool                        `json:"read_only,omitempty" yaml:"read_only,omitempty"`
+	CapAdd         []string                     `json:"cap_add,omitempty" yaml:"cap_add,omitempty"`
+	CapDrop        []string                     `json:"cap_drop,omitempty" yaml:"cap_drop,omitempty"`
+	Runtime        string                       `json:"runtime,omitempty" yaml:"runtime,omitempty"`
+	CgroupParent   string                       `json:"cgroup_parent,omitempty" yaml:"cgroup_parent,omitempty"`
+	BlkioConfig    *ComposeBlkioConfig          `json:"blkio_config,omitempty" yaml:"blkio_config,omitempty"`
+	StorageOpt     map[string]string            `json:"storage_opt,omitempty" yaml:"storage_opt,omitempty"`
+	Command        ComposeCommandConfig         `json:"command,omitempty" yaml:"command,omitempty"`
+	Entrypoint     ComposeEntrypointConfig      `json:"entrypoint,omitempty" yaml:"entrypoint,omitempty"`
+	User           string                       `json:"user,omitempty" yaml:"user,omitempty"`
+	WorkingDir     string                       `json:"working_dir,omitempty" yaml:"working_dir,omitempty"`
+	// RawExtra keeps the YAML of service keys this package does not model yet,
+	// written back after the known fields on export. JSON ignores it.
+	RawExtra map[string]yaml.Node `json:"-" yaml:"-"`
+}
+
+// serviceKeyOrder is the order in which docker compose config writes service
+// keys, taken from the compose-go ServiceConfig struct.
+var serviceKeyOrder = indexKeys([]string{
+	"name", "profiles", "annotations", "attach", "build", "develop",
+	"blkio_config", "cap_add", "cap_drop", "cgroup_parent", "cgroup",
+	"cpu_count", "cpu_percent", "cpu_period", "cpu_quota", "cpu_rt_period",
+	"cpu_rt_runtime", "cpus", "cpuset", "cpu_shares", "command", "configs",
+	"container_name", "credential_spec", "depends_on", "deploy",
+	"device_cgroup_rules", "devices", "dns", "dns_opt", "dns_search",
+	"dockerfile", "domainname", "entrypoint", "provider", "environment",
+	"env_file", "expose", "extends", "external_links", "extra_hosts",
+	"group_add", "gpus", "hostname", "healthcheck", "image", "init", "ipc",
+	"isolation", "labels", "label_file", "links", "logging", "log_driver",
+	"log_opt", "mem_limit", "mem_reservation", "memswap_limit",
+	"mem_swappiness", "mac_address", "models", "net", "network_mode",
+	"networks", "oom_kill_disable", "oom_score_adj", "pid", "pids_limit",
+	"platform", "ports", "privileged", "pull_policy", "read_only", "restart",
+	"runtime", "scale", "secrets", "security_opt", "shm_size", "stdin_open",
+	"stop_grace_period", "stop_signal", "storage_opt", "sysctls", "tmpfs",
+	"tty", "ulimits", "use_api_socket", "user", "userns_mode", "uts",
+	"volume_driver", "volumes", "volumes_from", "working_dir", "pre_start",
+	"post_start", "pre_stop",
+})
+
+func indexKeys(keys []string) map[string]int {
+	index := make(map[string]int, len(keys))
+	for i, key := range keys {
+		index[key] = i
+	}
+	return index
+}
+
+// serviceFields holds the keys of the modeled service fields.
+var serviceFields = yamlFields(reflect.TypeOf(ComposeServiceConfig{}))
+
+func yamlFields(t reflect.Type) map[string]bool {
+	fields := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		if name := yamlFieldName(t.Field(i)); name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}
+
+// UnmarshalYAML decodes the modeled fields and keeps every other key in
+// RawExtra.
+func (serviceConf *ComposeServiceConfig) UnmarshalYAML(node *yaml.Node) error {
+	type plain ComposeServiceConfig
+	if err := node.Decode((*plain)(serviceConf)); err != nil {
+		return err
+	}
+	extra := map[string]yaml.Node{}
+	collectRawExtra(node, extra)
+	serviceConf.RawExtra = nil
+	if len(extra) > 0 {
+		serviceConf.RawExtra = extra
+	}
+	return nil
+}
+
+// collectRawExtra stores the unknown keys of node in extra, following merge
+// keys the way the decoder does: keys of the mapping itself win over merged
+// ones, and earlier merged mappings over later ones.
+func collectRawExtra(node *yaml.Node, extra map[string]yaml.Node) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	merged := []*yaml.Node{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if isMergeKey(key) {
+			if value.Kind == yaml.SequenceNode {
+				merged = append(merged, value.Content...)
+			} else {
+				merged = append(merged, value)
+			}
+			continue
+		}
+		if _, ok := extra[key.Value]; ok || serviceFields[key.Value] {
+			continue
+		}
+		value = resolveAliases(value)
+		untagMergeKeys(value)
+		extra[key.Value] = *value
+	}
+	for _, value := range merged {
+		collectRawExtra(value, extra)
+	}
+}
+
+func isMergeKey(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Value == "<<" && (node.Tag == "" || node.Tag == "!!merge")
+}
+
+// untagMergeKeys drops the explicit !!merge tag the decoder leaves on merge
+// keys, which the encoder would otherwise write out.
+func untagMergeKeys(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if isMergeKey(node.Content[i]) {
+				node.Content[i].Tag = ""
+			}
+		}
+	}
+	for _, child := range node.Content {
+		untagMergeKeys(child)
+	}
+}
+
+// resolveAliases returns node unchanged unless it refers to anchors, in which
+// case it returns a copy with the aliases expanded, since the anchors are not
+// written back.
+func resolveAliases(node *yaml.Node) *yaml.Node {
+	if !containsAlias(node) {
+		return node
+	}
+	var value any
+	resolved := &yaml.Node{}
+	if err := node.Decode(&value); err != nil || resolved.Encode(value) != nil {
+		return node
+	}
+	return resolved
+}
+
+func containsAlias(node *yaml.Node) bool {
+	if node.Kind == yaml.AliasNode {
+		return true
+	}
+	for _, child := range node.Content {
+		if containsAlias(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalYAML writes the service keys in the order docker compose config uses,
+// so exported files diff cleanly against its output, keys it does not list
+// last. RawExtra keys are dropped when a modeled field wrote the same key.
+func (serviceConf *ComposeServiceConfig) MarshalYAML() (any, error) {
+	type plain ComposeServiceConfig
+	node := &yaml.Node{}
+	if err := node.Encode((*plain)(serviceConf)); err != nil {
+		return nil, err
+	}
+	for _, key := range sortedKeys(serviceConf.RawExtra) {
+		if mappingValue(node, key) != nil {
+			continue
+		}
+		value := serviceConf.RawExtra[key]
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, &value)
+	}
+	sortMappingKeys(node, serviceKeyOrder)
+	return node, nil
+}
+
+// sortMappingKeys stably reorders the pairs of a mapping node by order.
+func sortMappingKeys(node *yaml.Node, order map[string]int) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	pairs := make([][2]*yaml.Node, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		pairs = append(pairs, [2]*yaml.Node{node.Content[i], node.Content[i+1]})
+	}
+	rank := func(key string) int {
+		if i, ok := order[key]; ok {
+			return i
+		}
+		return len(order)
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return rank(pairs[i][0].Value) < rank(pairs[j][0].Value)
+	})
+	node.Content = node.Content[:0]
+	for _, pair := range pairs {
+		node.Content = append(node.Content, pair[0], pair[1])
+	}
+}
+
+// PidTargetService returns the service whose pid namespace is shared through
+// the pid: "service:<name>" form.
+func (serviceConf *ComposeServiceConfig) PidTargetService() (string, bool) {
+	name, ok := strings.CutPrefix(serviceConf.Pid, "service:")
+	return name, ok && name != ""
+}
+
+// RequestsGPU reports whether the service asks for a GPU, either through the
+// legacy nvidia runtime or a device reservation with the gpu capability.
+func (serviceConf *ComposeServiceConfig) RequestsGPU() bool {
+	if serviceConf.Runtime == "nvidia" {
+		return true
+	}
+	if serviceConf.Deploy == nil || serviceConf.Deploy.Resources == nil || serviceConf.Deploy.Resources.Reservations == nil {
+		return false
+	}
+	for _, device := range serviceConf.Deploy.Resources.Reservations.Devices {
+		for _, capability := range device.Capabilities {
+			if capability == "gpu" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NormalizeScaleToDeploy moves the legacy scale field into deploy.replicas.
+// Conflicting values are left in place for Validate to report.
+func (serviceConf *ComposeServiceConfig) NormalizeScaleToDeploy() {
+	if serviceConf.Scale == nil {
+		return
+	}
+	if serviceConf.Deploy == nil {
+		serviceConf.Deploy = &ComposeDeployConfig{}
+	}
+	if serviceConf.Deploy.Replicas != nil && *serviceConf.Deploy.Replicas != *serviceConf.Scale {
+		return
+	}
+	replicas := *serviceConf.Scale
+	serviceConf.Deploy.Replicas = &replicas
+	serviceConf.Scale = nil
+}
+
+// NormalizeResourcesToDeploy moves the legacy mem_limit and mem_reservation
+// fields into deploy.resources. A legacy value is left in place when deploy
+// already sets a different one. The Windows cpu_count and cpu_percent fields
+// have no deploy counterpart and are kept as they are.
+func (serviceConf *ComposeServiceConfig) NormalizeResourcesToDeploy() {
+	if serviceConf.MemLimit == "" && serviceConf.MemReservation == "" {
+		return
+	}
+	if serviceConf.Deploy == nil {
+		serviceConf.Deploy = &ComposeDeployConfig{}
+	}
+	if serviceConf.Deploy.Resources == nil {
+		serviceConf.Deploy.Resources = &ComposeResourcesConfig{}
+	}
+	resources := serviceConf.Deploy.Resources
+	moveMemoryToResource(&serviceConf.MemLimit, &resources.Limits)
+	moveMemoryToResource(&serviceConf.MemReservation, &resources.Reservations)
+}
+
+func moveMemoryToResource(legacy *string, resource **ComposeResourceConfig) {
+	if *legacy == "" {
+		return
+	}
+	if *resource == nil {
+		*resource = &ComposeResourceConfig{}
+	}
+	if current := (*resource).Memory; current != "" && !sameByteSize(current, *legacy) {
+		return
+	}
+	(*resource).Memory = *legacy
+	*legacy = ""
+}
+
+func sameByteSize(a, b string) bool {
+	if a == b {
+		return true
+	}
+	aBytes, errA := ParseBytes(a)
+	bBytes, errB := ParseBytes(b)
+	return errA == nil && errB == nil && aBytes == bBytes
+}
+
+func (serviceConf *ComposeServiceConfig) ShmSizeBytes() (int64, error) {
+	return ParseBytes(serviceConf.ShmSize)
+}
+
+func (serviceConf *ComposeServiceConfig) MemLimitBytes() (int64, error) {
+	return ParseBytes(serviceConf.MemLimit)
 }
 
 func (serviceConf *ComposeServiceConfig) GetVersion() string {
@@ -174,6 +1022,18 @@ func (serviceConf *ComposeServiceConfig) SetVersion(version string) {
 	serviceConf.Image = fmt.Sprintf("%s:%s", serviceConf.GetImageName(), version)
 }
 
+// IsPrivileged reports whether the service runs privileged. Privileged is a
+// pointer so that an override can set it back to false; nil means false.
+func (serviceConf *ComposeServiceConfig) IsPrivileged() bool {
+	return serviceConf.Privileged != nil && *serviceConf.Privileged
+}
+
+// IsReadOnly reports whether the root filesystem of the service is mounted
+// read-only. ReadOnly is a pointer for the same reason as Privileged.
+func (serviceConf *ComposeServiceConfig) IsReadOnly() bool {
+	return serviceConf.ReadOnly != nil && *serviceConf.ReadOnly
+}
+
 func (serviceConf *ComposeServiceConfig) GetImageName() string {
 	match := regServiceImage.FindAllStringSubmatch(serviceConf.Image, -1)
 	if len(match) > 0 {
@@ -182,15 +1042,23 @@ func (serviceConf *ComposeServiceConfig) GetImageName() string {
 	return ""
 }
 
-func (serviceConf *ComposeServiceConfig) GetGitRegistry() string {
-	if serviceConf.Labels == nil {
-		return ""
-	}
-	registry, ok := (*serviceConf.Labels)["git.repository"]
-	if ok {
-		return registry
+func (serviceConf *ComposeServiceConfig) ParsePullPolicy() (PullPolicy, error) {
+	switch policy := PullPolicy(serviceConf.PullPolicy); policy {
+	case PullPolicyAlways, PullPolicyNever, PullPolicyMissing, PullPolicyBuild:
+		return policy, nil
+	case "":
+		return PullPolicyMissing, nil
+	default:
+		return "", fmt.Errorf("invalid pull_policy: %s", serviceConf.PullPolicy)
 	}
-	return ""
+}
+
+func (serviceConf *ComposeServiceConfig) GetGitRegistry() string {
+	return serviceConf.GitMetadata().Repository
+}
+
+func (serviceConf *ComposeServiceConfig) SetGitRegistry(repo string) {
+	serviceConf.SetLabel(LabelGitRepository, repo)
 }
 
 /*type ComposeServicesConfig []*ComposeServiceConfig
@@ -226,6 +1094,36 @@ func (servicesConf *ComposeServicesConfig) MarshalYAML() (any, error) {
 
 type ComposeServicesConfig map[string]*ComposeServiceConfig
 
+func (servicesConf *ComposeServicesConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return newParseError(node, "services", "invalid services format")
+	}
+	*servicesConf = make(map[string]*ComposeServiceConfig)
+	for i := 0; i < len(node.Content); i += 2 {
+		serviceName := node.Content[i].Value
+		serviceConf, err := decodeService(serviceName, node.Content[i+1])
+		if err != nil {
+			return err
+		}
+		(*servicesConf)[serviceName] = serviceConf
+	}
+	return nil
+}
+
+func decodeService(serviceName string, node *yaml.Node) (*ComposeServiceConfig, error) {
+	serviceConf := &ComposeServiceConfig{}
+	if err := node.Decode(serviceConf); err != nil {
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			parseErr.Field = fmt.Sprintf("services.%s.%s", serviceName, parseErr.Field)
+			return nil, parseErr
+		}
+		return nil, fmt.Errorf("services.%s: %w", serviceName, err)
+	}
+	serviceConf.ServiceName = serviceName
+	return serviceConf, nil
+}
+
 func (servicesConf *ComposeServicesConfig) MarshalYAML() (any, error) {
 	keys := make([]string, 0)
 	for key, _ := range *servicesConf {
@@ -240,19 +1138,25 @@ func (servicesConf *ComposeServicesConfig) MarshalYAML() (any, error) {
 }
 
 type ComposeConfig struct {
-	Version  string                           `json:"version" yaml:"version"`
-	Services *ComposeServicesConfig           `json:"services" yaml:"services"`
-	Networks map[string]*ComposeNetworkConfig `json:"networks,omitempty" yaml:"networks,omitempty"`
-	Volumes  map[string]types.VolumeConfig    `json:"volumes,omitempty" yaml:"volumes,omitempty"`
-	Secrets  map[string]types.SecretConfig    `json:"secrets,omitempty" yaml:"secrets,omitempty"`
+	Version  string                             `json:"version,omitempty" yaml:"version,omitempty"`
+	Include  ComposeIncludesConfig              `json:"include,omitempty" yaml:"include,omitempty"`
+	Services *ComposeServicesConfig             `json:"services" yaml:"services"`
+	Networks map[string]*ComposeNetworkConfig   `json:"networks,omitempty" yaml:"networks,omitempty"`
+	Volumes  map[string]*ComposeVolumeConfig    `json:"volumes,omitempty" yaml:"volumes,omitempty"`
+	Secrets  map[string]*ComposeSecretConfig    `json:"secrets,omitempty" yaml:"secrets,omitempty"`
+	Configs  map[string]*ComposeConfigObjConfig `json:"configs,omitempty" yaml:"configs,omitempty"`
 }
 
+// ExportYAML renders the config with the encoder defaults, double-quoting
+// strings that would otherwise be read back as another type. The encoder
+// sorts map keys, so networks, volumes, secrets and configs come out in key
+// order and exporting the same config twice gives the same bytes.
 func (conf *ComposeConfig) ExportYAML() ([]byte, error) {
-	return yaml.Marshal(conf)
+	return conf.ExportYAMLWithOptions(YAMLOptions{})
 }
 
 func (conf *ComposeConfig) ExportJSON() ([]byte, error) {
-	return jsoniter.Marshal(conf)
+	return sortedJSON.Marshal(conf)
 }
 
 func (conf *ComposeConfig) GetService(name string) *ComposeServiceConfig {
@@ -271,27 +1175,135 @@ func (conf *ComposeConfig) GetService(name string) *ComposeServiceConfig {
 	return service*/
 }
 
+// LookupService is GetService with an error wrapping ErrServiceNotFound for
+// unknown names.
+func (conf *ComposeConfig) LookupService(name string) (*ComposeServiceConfig, error) {
+	if conf.Services != nil {
+		if service := (*conf.Services)[name]; service != nil {
+			return service, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrServiceNotFound, name)
+}
+
+// MustService is LookupService for tests and setup code; it panics listing
+// the available services when name is unknown.
+func (conf *ComposeConfig) MustService(name string) *ComposeServiceConfig {
+	service, err := conf.LookupService(name)
+	if err != nil {
+		panic(fmt.Sprintf("%v (available: %s)", err, strings.Join(conf.ServiceNames(), ", ")))
+	}
+	return service
+}
+
+func (conf *ComposeConfig) GetServiceByContainerName(name string) *ComposeServiceConfig {
+	for _, serviceName := range conf.ServiceNames() {
+		if service := (*conf.Services)[serviceName]; service.ContainerName == name {
+			return service
+		}
+	}
+	return nil
+}
+
+// FindServicesByImage returns the services, sorted by name, whose image equals
+// pattern or matches it as a path.Match glob such as "registry.internal/*/worker".
+func (conf *ComposeConfig) FindServicesByImage(pattern string) []*ComposeServiceConfig {
+	services := []*ComposeServiceConfig{}
+	for _, serviceName := range conf.ServiceNames() {
+		service := (*conf.Services)[serviceName]
+		if service.Image == "" {
+			continue
+		}
+		if matched, _ := path.Match(pattern, service.Image); matched || service.Image == pattern {
+			services = append(services, service)
+		}
+	}
+	return services
+}
+
+func (conf *ComposeConfig) NormalizeScaleToDeploy() {
+	for _, name := range conf.ServiceNames() {
+		(*conf.Services)[name].NormalizeScaleToDeploy()
+	}
+}
+
+func (conf *ComposeConfig) NormalizeResourcesToDeploy() {
+	for _, name := range conf.ServiceNames() {
+		(*conf.Services)[name].NormalizeResourcesToDeploy()
+	}
+}
+
+func (conf *ComposeConfig) ServiceNames() []string {
+	if conf.Services == nil {
+		return []string{}
+	}
+	return sortedKeys(*conf.Services)
+}
+
 func (conf *ComposeConfig) SetService(name string, serviceConf *ComposeServiceConfig) {
 	(*conf.Services)[name] = serviceConf
 }
 
+// GetConfigFromComposeFile reads "-" from stdin, detecting JSON by its
+// leading byte and defaulting to YAML.
 func GetConfigFromComposeFile(composeFilePath string) (*ComposeConfig, error) {
-	ext := filepath.Ext(composeFilePath)
-	content, err := os.ReadFile(composeFilePath)
-	if err != nil {
-		return nil, err
+	if composeFilePath == "-" {
+		return GetConfigFromComposeFileWithFormat(composeFilePath, FormatAuto)
 	}
-	config := &ComposeConfig{}
+	ext := filepath.Ext(composeFilePath)
+	var format Format
 	switch ext {
 	case ".yml", ".yaml":
-		err = yaml.Unmarshal(content, config)
+		format = FormatYAML
 	case ".json":
-		err = jsoniter.Unmarshal(content, config)
+		format = FormatJSON
 	default:
-		err = fmt.Errorf("unsupported compose file format: %s", ext)
+		return getConfigBySniffing(composeFilePath)
+	}
+	return GetConfigFromComposeFileWithFormat(composeFilePath, format)
+}
+
+// getConfigBySniffing loads a file without a known extension, trying the
+// format suggested by its content first and then the other one.
+func getConfigBySniffing(composeFilePath string) (*ComposeConfig, error) {
+	content, err := os.ReadFile(composeFilePath)
+	if err != nil {
+		return nil, err
+	}
+	first, second := FormatYAML, FormatJSON
+	if sniffFormat(content) == FormatJSON {
+		first, second = FormatJSON, FormatYAML
+	}
+	config, firstErr := GetConfigFromBytes(content, first)
+	if firstErr == nil {
+		return config, nil
+	}
+	config, secondErr := GetConfigFromBytes(content, second)
+	if secondErr == nil {
+		return config, nil
+	}
+	errs := &MultiError{}
+	errs.Append(withSource(fmt.Errorf("as %s: %w", first, firstErr), composeFilePath))
+	errs.Append(withSource(fmt.Errorf("as %s: %w", second, secondErr), composeFilePath))
+	return nil, errs
+}
+
+func GetConfigFromComposeFileWithFormat(composeFilePath string, format Format) (*ComposeConfig, error) {
+	var content []byte
+	var err error
+	source := composeFilePath
+	if composeFilePath == "-" {
+		source = "<stdin>"
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(composeFilePath)
 	}
 	if err != nil {
 		return nil, err
 	}
+	config, err := GetConfigFromBytes(content, format)
+	if err != nil {
+		return nil, withSource(err, source)
+	}
 	return config, nil
 }
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const dependsOnJSON = `{
+  "services": {
+    "web": {"image": "nginx", "depends_on": ["db", "cache"]},
+    "worker": {"image": "worker", "depends_on": {"db": {"condition": "service_healthy"}, "cache": {"condition": "service_started"}}},
+    "db": {"image": "postgres"},
+    "cache": {"image": "redis"}
+  }
+}`
+
+func TestDependsOnJSONRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "compose.json")
+	if err := os.WriteFile(path, []byte(dependsOnJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conf, err := GetConfigFromComposeFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string]string{
+		"web":    {"db": "", "cache": ""},
+		"worker": {"db": "service_healthy", "cache": "service_started"},
+	}
+	checkDependsOn(t, conf, want)
+
+	content, err := conf.ExportJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported := filepath.Join(dir, "exported.json")
+	if err := os.WriteFile(exported, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := GetConfigFromComposeFile(exported)
+	if err != nil {
+		t.Fatalf("%v in:\n%s", err, content)
+	}
+	checkDependsOn(t, reloaded, want)
+}
+
+func checkDependsOn(t *testing.T, conf *ComposeConfig, want map[string]map[string]string) {
+	t.Helper()
+	for name, deps := range want {
+		got := map[string]string{}
+		serviceConf := (*conf.Services)[name]
+		if serviceConf.DependsOn != nil {
+			for service, dep := range *serviceConf.DependsOn {
+				if dep.ServiceName != service {
+					t.Errorf("%s depends on %s under the name %q", name, service, dep.ServiceName)
+				}
+				got[service] = dep.Condition
+			}
+		}
+		if !reflect.DeepEqual(got, deps) {
+			t.Errorf("%s depends on %v, want %v", name, got, deps)
+		}
+	}
+}
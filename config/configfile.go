@@ -0,0 +1,73 @@
+package config
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ConfigFile keeps the config parsed from a file together with the SHA-256 of
+// its content, to tell whether the file changed since.
+type ConfigFile struct {
+	path string
+
+	mu   sync.RWMutex
+	conf *ComposeConfig
+	hash [sha256.Size]byte
+}
+
+func OpenComposeFile(path string) (*ConfigFile, error) {
+	file := &ConfigFile{path: path}
+	if _, err := file.Reload(); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (file *ConfigFile) Path() string {
+	return file.path
+}
+
+// Config returns the config of the last successful load. Reload replaces it
+// rather than changing it, so the returned value is a stable snapshot.
+func (file *ConfigFile) Config() *ComposeConfig {
+	file.mu.RLock()
+	defer file.mu.RUnlock()
+	return file.conf
+}
+
+// Reload parses the file again when its content hash changed. The content is
+// always read and hashed, as a rewrite within the resolution of the
+// modification time keeps both it and possibly the size. A failed parse keeps
+// the previous config.
+func (file *ConfigFile) Reload() (changed bool, err error) {
+	content, err := os.ReadFile(file.path)
+	if err != nil {
+		return false, err
+	}
+	hash := sha256.Sum256(content)
+
+	file.mu.Lock()
+	defer file.mu.Unlock()
+	if file.conf != nil && hash == file.hash {
+		return false, nil
+	}
+	conf, err := GetConfigFromBytes(content, formatForPath(file.path))
+	if err != nil {
+		return false, withSource(err, file.path)
+	}
+	file.conf, file.hash = conf, hash
+	return true, nil
+}
+
+func formatForPath(path string) Format {
+	switch filepath.Ext(path) {
+	case ".yml", ".yaml":
+		return FormatYAML
+	case ".json":
+		return FormatJSON
+	default:
+		return FormatAuto
+	}
+}
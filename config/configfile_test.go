@@ -0,0 +1,39 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReloadSeesRewritesKeepingSizeAndModTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "compose.yml")
+	if err := os.WriteFile(path, []byte("services:\n  web:\n    image: app:1.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := OpenComposeFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := file.Reload(); err != nil || changed {
+		t.Fatalf("Reload of the same file = %v, %v", changed, err)
+	}
+
+	if err := os.WriteFile(path, []byte("services:\n  web:\n    image: app:2.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := file.Reload()
+	if err != nil || !changed {
+		t.Fatalf("Reload of the rewritten file = %v, %v", changed, err)
+	}
+	if image := file.Config().GetService("web").Image; image != "app:2.0" {
+		t.Errorf("got image %s, want app:2.0", image)
+	}
+}
@@ -0,0 +1,53 @@
+package config
+
+// ServiceDefaults are settings stamped onto every service by ApplyDefaults.
+// Zero fields are ignored.
+type ServiceDefaults struct {
+	Restart     string
+	Logging     *ComposeLoggingConfig
+	Labels      map[string]string
+	Environment map[string]string
+	PullPolicy  string
+	// Force overwrites values the services already set instead of only
+	// filling in missing ones.
+	Force bool
+}
+
+// ApplyDefaults fills in d on every service. Labels and environment variables
+// are applied key by key.
+func (conf *ComposeConfig) ApplyDefaults(d ServiceDefaults) {
+	for _, name := range conf.ServiceNames() {
+		(*conf.Services)[name].applyDefaults(d, func(string) {})
+	}
+}
+
+// applyDefaults calls applied with the field of every default it sets.
+func (serviceConf *ComposeServiceConfig) applyDefaults(d ServiceDefaults, applied func(field string)) {
+	if d.Restart != "" && (d.Force || serviceConf.Restart == "") {
+		serviceConf.Restart = d.Restart
+		applied("restart")
+	}
+	if d.PullPolicy != "" && (d.Force || serviceConf.PullPolicy == "") {
+		serviceConf.PullPolicy = d.PullPolicy
+		applied("pull_policy")
+	}
+	if d.Logging != nil && (d.Force || serviceConf.Logging == nil) {
+		serviceConf.Logging = d.Logging.Clone()
+		applied("logging")
+	}
+	for _, key := range sortedKeys(d.Labels) {
+		if _, ok := serviceConf.GetLabel(key); d.Force || !ok {
+			serviceConf.SetLabel(key, d.Labels[key])
+			applied("labels." + key)
+		}
+	}
+	for _, key := range sortedKeys(d.Environment) {
+		if serviceConf.Environment == nil {
+			serviceConf.Environment = &ComposeEnvironmentConfig{}
+		}
+		if _, ok := serviceConf.Environment.Get(key); d.Force || !ok {
+			serviceConf.Environment.Set(key, d.Environment[key])
+			applied("environment." + key)
+		}
+	}
+}
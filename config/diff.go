@@ -0,0 +1,153 @@
+package config
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+type ChangeKind string
+
+const (
+	ChangeAdded   ChangeKind = "added"
+	ChangeRemoved ChangeKind = "removed"
+	ChangeChanged ChangeKind = "changed"
+)
+
+type FieldChange struct {
+	Path string
+	Kind ChangeKind
+	Old  any
+	New  any
+}
+
+func (c FieldChange) String() string {
+	switch c.Kind {
+	case ChangeAdded:
+		return fmt.Sprintf("+ %s: %v", c.Path, c.New)
+	case ChangeRemoved:
+		return fmt.Sprintf("- %s: %v", c.Path, c.Old)
+	default:
+		return fmt.Sprintf("~ %s: %v -> %v", c.Path, c.Old, c.New)
+	}
+}
+
+type ComposeDiff struct {
+	Changes []FieldChange
+}
+
+func (d *ComposeDiff) Empty() bool {
+	return len(d.Changes) == 0
+}
+
+func (d *ComposeDiff) String() string {
+	lines := make([]string, 0, len(d.Changes))
+	for _, change := range d.Changes {
+		lines = append(lines, change.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Diff compares two configs by their exported YAML form, so fields that
+// marshal identically compare equal.
+func Diff(a, b *ComposeConfig) (*ComposeDiff, error) {
+	left, err := toGeneric(a)
+	if err != nil {
+		return nil, err
+	}
+	right, err := toGeneric(b)
+	if err != nil {
+		return nil, err
+	}
+	diff := &ComposeDiff{Changes: []FieldChange{}}
+	diffValues("", left, right, diff)
+	return diff, nil
+}
+
+func CompareFiles(pathA, pathB string) (*ComposeDiff, error) {
+	a, err := GetConfigFromComposeFile(pathA)
+	if err != nil {
+		return nil, err
+	}
+	b, err := GetConfigFromComposeFile(pathB)
+	if err != nil {
+		return nil, err
+	}
+	if err = a.Normalize(NormalizeOptions{WorkingDir: filepath.Dir(pathA)}); err != nil {
+		return nil, err
+	}
+	if err = b.Normalize(NormalizeOptions{WorkingDir: filepath.Dir(pathB)}); err != nil {
+		return nil, err
+	}
+	return Diff(a, b)
+}
+
+func toGeneric(conf *ComposeConfig) (any, error) {
+	content, err := conf.ExportYAML()
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	if err = yaml.Unmarshal(content, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func diffValues(path string, left, right any, diff *ComposeDiff) {
+	leftMap, leftIsMap := left.(map[string]any)
+	rightMap, rightIsMap := right.(map[string]any)
+	if leftIsMap && rightIsMap {
+		keys := map[string]bool{}
+		for key := range leftMap {
+			keys[key] = true
+		}
+		for key := range rightMap {
+			keys[key] = true
+		}
+		for _, key := range sortedKeys(keys) {
+			childPath := joinPath(path, key)
+			l, inLeft := leftMap[key]
+			r, inRight := rightMap[key]
+			switch {
+			case !inLeft:
+				diff.Changes = append(diff.Changes, FieldChange{Path: childPath, Kind: ChangeAdded, New: r})
+			case !inRight:
+				diff.Changes = append(diff.Changes, FieldChange{Path: childPath, Kind: ChangeRemoved, Old: l})
+			default:
+				diffValues(childPath, l, r, diff)
+			}
+		}
+		return
+	}
+
+	leftList, leftIsList := left.([]any)
+	rightList, rightIsList := right.([]any)
+	if leftIsList && rightIsList {
+		for i := 0; i < len(leftList) || i < len(rightList); i++ {
+			childPath := joinPath(path, fmt.Sprint(i))
+			switch {
+			case i >= len(leftList):
+				diff.Changes = append(diff.Changes, FieldChange{Path: childPath, Kind: ChangeAdded, New: rightList[i]})
+			case i >= len(rightList):
+				diff.Changes = append(diff.Changes, FieldChange{Path: childPath, Kind: ChangeRemoved, Old: leftList[i]})
+			default:
+				diffValues(childPath, leftList[i], rightList[i], diff)
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(left, right) {
+		diff.Changes = append(diff.Changes, FieldChange{Path: path, Kind: ChangeChanged, Old: left, New: right})
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
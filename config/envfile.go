@@ -0,0 +1,267 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func ReadEnvFile(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseEnvFile(content)
+}
+
+func ParseEnvFile(content []byte) (map[string]string, error) {
+	env := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("%w: env file line %d: %s", ErrInvalidEnvironment, lineNumber, line)
+		}
+		if !found {
+			// a bare key takes its value from the current process environment
+			if value, ok := os.LookupEnv(key); ok {
+				env[key] = value
+			}
+			continue
+		}
+		env[key] = parseEnvFileValue(strings.TrimSpace(value))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+func parseEnvFileValue(value string) string {
+	if len(value) >= 2 {
+		quote := value[0]
+		if (quote == '"' || quote == '\'') && value[len(value)-1] == quote {
+			value = value[1 : len(value)-1]
+			if quote == '"' {
+				value = strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`).Replace(value)
+			}
+			return value
+		}
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value
+}
+
+// ResolveEnvFiles returns the effective environment of the service: env files
+// in declaration order (later files win), then the inline environment on top.
+// Missing files are skipped when the entry sets required: false. Null inline
+// variables leave the value of the env files in place, and are left out
+// without one.
+func (serviceConf *ComposeServiceConfig) ResolveEnvFiles(baseDir string) (map[string]string, error) {
+	env, err := serviceConf.envFileValues(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	if serviceConf.Environment != nil {
+		for key, value := range serviceConf.Environment.Values {
+			if !serviceConf.Environment.IsNull(key) {
+				env[key] = value
+			}
+		}
+	}
+	return env, nil
+}
+
+// envFileValues merges the env files of the service, later files winning.
+func (serviceConf *ComposeServiceConfig) envFileValues(baseDir string) (map[string]string, error) {
+	env := map[string]string{}
+	errs := &MultiError{}
+	for _, envFile := range serviceConf.EnvFile {
+		path := envFile.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		fileEnv, err := ReadEnvFile(path)
+		if err != nil {
+			if !envFile.IsRequired() && errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			errs.Append(err)
+			continue
+		}
+		for key, value := range fileEnv {
+			env[key] = value
+		}
+	}
+	if err := errs.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+func (conf *ComposeConfig) InlineEnvFiles(baseDir string) error {
+	if conf.Services == nil {
+		return nil
+	}
+	errs := &MultiError{}
+	for _, name := range conf.ServiceNames() {
+		serviceConf := (*conf.Services)[name]
+		if len(serviceConf.EnvFile) == 0 {
+			continue
+		}
+		fileEnv, err := serviceConf.envFileValues(baseDir)
+		if err != nil {
+			appendServiceErrors(errs, name, err)
+			continue
+		}
+		// the inline variables keep their order and stay null unless an env
+		// file sets them, the others follow sorted
+		env := &ComposeEnvironmentConfig{Values: map[string]string{}}
+		if inline := serviceConf.Environment; inline != nil {
+			for _, key := range inline.Keys() {
+				value, fromFile := fileEnv[key]
+				switch {
+				case !inline.IsNull(key):
+					env.add(key, inline.Values[key])
+				case fromFile:
+					env.add(key, value)
+				default:
+					env.addNull(key)
+				}
+			}
+		}
+		for _, key := range sortedKeys(fileEnv) {
+			if _, ok := env.Values[key]; !ok {
+				env.add(key, fileEnv[key])
+			}
+		}
+		serviceConf.Environment = env
+		serviceConf.EnvFile = nil
+	}
+	return errs.ErrorOrNil()
+}
+
+// ServiceEnvFile renders the effective environment of the service (env files,
+// inline environment and interpolation through lookup) as a .env file with
+// sorted keys. Null variables the env files do not set take their value from
+// lookup, as compose takes them from the shell, and are left out without one.
+// Values are single quoted when the shell would otherwise split
+// or expand them, so the result is shell-sourceable and can serve as a
+// compose env_file. Use ServiceDockerEnvFile for docker run --env-file, which
+// keeps the quotes as part of the values.
+func (conf *ComposeConfig) ServiceEnvFile(serviceName, baseDir string, lookup func(string) (string, bool)) ([]byte, error) {
+	env, err := conf.serviceEnv(serviceName, baseDir, lookup)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	for _, key := range sortedKeys(env) {
+		if !isShellName(key) {
+			return nil, fmt.Errorf("service %s: %w: variable %q is not a valid shell name", serviceName, ErrInvalidEnvironment, key)
+		}
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(shellQuote(env[key]))
+		b.WriteByte('\n')
+	}
+	return b.Bytes(), nil
+}
+
+// ServiceDockerEnvFile renders the same environment as ServiceEnvFile in the
+// format of docker run --env-file: raw KEY=value lines, without quoting. The
+// format has no escapes, so a value holding a newline is an error, and so is a
+// key docker would read differently.
+func (conf *ComposeConfig) ServiceDockerEnvFile(serviceName, baseDir string, lookup func(string) (string, bool)) ([]byte, error) {
+	env, err := conf.serviceEnv(serviceName, baseDir, lookup)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	for _, key := range sortedKeys(env) {
+		if key == "" || key[0] == '#' || strings.ContainsAny(key, "= \t\r\n") {
+			return nil, fmt.Errorf("service %s: %w: variable %q cannot be written to a docker env file", serviceName, ErrInvalidEnvironment, key)
+		}
+		value := env[key]
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("service %s: %w: the value of %s holds a newline, which a docker env file cannot represent", serviceName, ErrInvalidEnvironment, key)
+		}
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(value)
+		b.WriteByte('\n')
+	}
+	return b.Bytes(), nil
+}
+
+// serviceEnv resolves the environment of the service for the env file
+// renderers, interpolating the values through lookup when it is set.
+func (conf *ComposeConfig) serviceEnv(serviceName, baseDir string, lookup func(string) (string, bool)) (map[string]string, error) {
+	serviceConf, err := conf.LookupService(serviceName)
+	if err != nil {
+		return nil, err
+	}
+	env, err := serviceConf.ResolveEnvFiles(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("service %s: %w", serviceName, err)
+	}
+	if lookup == nil {
+		return env, nil
+	}
+	for key, value := range env {
+		if env[key], err = InterpolateString(value, lookup); err != nil {
+			return nil, fmt.Errorf("service %s: %w", serviceName, err)
+		}
+	}
+	if serviceConf.Environment != nil {
+		for _, key := range serviceConf.Environment.Keys() {
+			if _, ok := env[key]; ok || !serviceConf.Environment.IsNull(key) {
+				continue
+			}
+			if value, ok := lookup(key); ok {
+				env[key] = value
+			}
+		}
+	}
+	return env, nil
+}
+
+func isShellName(s string) bool {
+	if s == "" || !isVarNameStart(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isVarNameChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func shellQuote(s string) string {
+	safe := true
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("_-.,:/@%+=", c)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestResolveEnvFilesInlineEnvironmentWins(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"base.env":  "SHARED=base\nFROM_BASE=1\nOVERRIDDEN=base\n",
+		"later.env": "OVERRIDDEN=later\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conf := mustParse(t, `services:
+  web:
+    image: nginx
+    env_file:
+      - base.env
+      - later.env
+      - path: missing.env
+        required: false
+    environment:
+      SHARED: inline
+`)
+	env, err := (*conf.Services)["web"].ResolveEnvFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"SHARED": "inline", "FROM_BASE": "1", "OVERRIDDEN": "later"}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("got %v, want %v", env, want)
+	}
+}
+
+func TestNullInlineVariablesKeepEnvFileValues(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.env"), []byte("DEBUG=1\nLEVEL=info\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conf := mustParse(t, `services:
+  web:
+    image: nginx
+    env_file: app.env
+    environment:
+      - NAME=web
+      - DEBUG
+      - TOKEN
+`)
+	web := (*conf.Services)["web"]
+	env, err := web.ResolveEnvFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"NAME": "web", "DEBUG": "1", "LEVEL": "info"}; !reflect.DeepEqual(env, want) {
+		t.Errorf("ResolveEnvFiles = %v, want %v", env, want)
+	}
+
+	if err := conf.InlineEnvFiles(dir); err != nil {
+		t.Fatal(err)
+	}
+	environment := web.Environment
+	if keys := environment.Keys(); !reflect.DeepEqual(keys, []string{"NAME", "DEBUG", "TOKEN", "LEVEL"}) {
+		t.Errorf("got keys %v, want the inline order followed by the env file keys", keys)
+	}
+	if environment.Values["DEBUG"] != "1" || environment.IsNull("DEBUG") {
+		t.Errorf("DEBUG lost the env file value: %q", environment.Values["DEBUG"])
+	}
+	if !environment.IsNull("TOKEN") {
+		t.Error("TOKEN is no longer null")
+	}
+}
+
+func TestServiceEnvFileLooksUpNullVariables(t *testing.T) {
+	conf := mustParse(t, `services:
+  web:
+    image: nginx
+    environment:
+      - NAME=$$HOME
+      - TOKEN
+      - UNSET
+`)
+	lookup := func(name string) (string, bool) {
+		value, ok := map[string]string{"TOKEN": "a$b"}[name]
+		return value, ok
+	}
+	content, err := conf.ServiceEnvFile("web", t.TempDir(), lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "NAME='$HOME'\nTOKEN='a$b'\n"; string(content) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", content, want)
+	}
+}
+
+func TestServiceDockerEnvFileWritesRawValues(t *testing.T) {
+	conf := mustParse(t, `services:
+  web:
+    image: nginx
+    environment:
+      GREETING: hello world
+      QUOTED: "'a' \"b\""
+  multiline:
+    image: nginx
+    environment:
+      CERT: "line1\nline2"
+`)
+	content, err := conf.ServiceDockerEnvFile("web", t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "GREETING=hello world\nQUOTED='a' \"b\"\n"; string(content) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", content, want)
+	}
+	if _, err := conf.ServiceDockerEnvFile("multiline", t.TempDir(), nil); !errors.Is(err, ErrInvalidEnvironment) {
+		t.Errorf("got %v, want an error for the newline", err)
+	}
+}
@@ -0,0 +1,268 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"gopkg.in/yaml.v3"
+	"sort"
+)
+
+// ComposeEnvironmentConfig is a service environment that remembers the order
+// its variables were read in. Variables set later on, or added to Values
+// directly, are written after the original ones in sorted order.
+//
+// A variable declared without a value, as in "- DEBUG" or "DEBUG: null", is
+// null: it has an empty entry in Values and is written back without a value.
+type ComposeEnvironmentConfig struct {
+	Values map[string]string
+	keys   []string
+	nulls  map[string]bool
+}
+
+func NewComposeEnvironmentConfig(values map[string]string) *ComposeEnvironmentConfig {
+	e := &ComposeEnvironmentConfig{Values: make(map[string]string, len(values))}
+	for key, value := range values {
+		e.Values[key] = value
+	}
+	return e
+}
+
+func (e *ComposeEnvironmentConfig) Get(key string) (string, bool) {
+	value, ok := e.Values[key]
+	return value, ok
+}
+
+func (e *ComposeEnvironmentConfig) Set(key, value string) {
+	if e.Values == nil {
+		e.Values = map[string]string{}
+	}
+	e.Values[key] = value
+	delete(e.nulls, key)
+}
+
+// SetNull declares key without a value.
+func (e *ComposeEnvironmentConfig) SetNull(key string) {
+	e.Set(key, "")
+	if e.nulls == nil {
+		e.nulls = map[string]bool{}
+	}
+	e.nulls[key] = true
+}
+
+// IsNull reports whether key is declared without a value.
+func (e *ComposeEnvironmentConfig) IsNull(key string) bool {
+	if value, ok := e.Values[key]; !ok || value != "" {
+		return false
+	}
+	return e.nulls[key]
+}
+
+func (e *ComposeEnvironmentConfig) Delete(key string) {
+	delete(e.Values, key)
+	delete(e.nulls, key)
+}
+
+func (e *ComposeEnvironmentConfig) Len() int {
+	return len(e.Values)
+}
+
+// Keys returns the variable names in file order, followed by the names added
+// since in sorted order.
+func (e *ComposeEnvironmentConfig) Keys() []string {
+	return orderedKeys(e.keys, e.Values)
+}
+
+// orderedKeys returns the keys of values listed in order first, then the
+// others sorted.
+func orderedKeys(order []string, values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	seen := make(map[string]bool, len(values))
+	for _, key := range order {
+		if _, ok := values[key]; ok && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	added := []string{}
+	for key := range values {
+		if !seen[key] {
+			added = append(added, key)
+		}
+	}
+	sort.Strings(added)
+	return append(keys, added...)
+}
+
+func (e *ComposeEnvironmentConfig) add(key, value string) {
+	if _, ok := e.Values[key]; !ok {
+		e.keys = append(e.keys, key)
+	}
+	e.Values[key] = value
+	delete(e.nulls, key)
+}
+
+func (e *ComposeEnvironmentConfig) addNull(key string) {
+	e.add(key, "")
+	if e.nulls == nil {
+		e.nulls = map[string]bool{}
+	}
+	e.nulls[key] = true
+}
+
+// liveNulls returns the null variables still holding their empty value.
+func (e *ComposeEnvironmentConfig) liveNulls() map[string]bool {
+	nulls := map[string]bool{}
+	for key := range e.nulls {
+		if e.IsNull(key) {
+			nulls[key] = true
+		}
+	}
+	return nulls
+}
+
+func (e *ComposeEnvironmentConfig) UnmarshalYAML(node *yaml.Node) error {
+	e.Values, e.keys, e.nulls = map[string]string{}, nil, nil
+	return decodeKeyValuesYAML(node, "environment", ErrInvalidEnvironment, e.add, e.addNull)
+}
+
+// MarshalYAML emits the mapping form in Keys order.
+func (e ComposeEnvironmentConfig) MarshalYAML() (any, error) {
+	return encodeKeyValuesYAML(e.Keys(), e.Values, e.liveNulls())
+}
+
+// UnmarshalJSON accepts the KEY=VALUE list form as well as the object form,
+// keeping the order of either.
+func (e *ComposeEnvironmentConfig) UnmarshalJSON(data []byte) error {
+	e.Values, e.keys, e.nulls = map[string]string{}, nil, nil
+	return decodeKeyValuesJSON(data, "environment", ErrInvalidEnvironment, e.add, e.addNull)
+}
+
+func (e ComposeEnvironmentConfig) MarshalJSON() ([]byte, error) {
+	return encodeKeyValuesJSON(e.Keys(), e.Values, e.liveNulls())
+}
+
+// decodeKeyValuesYAML reads the KEY=VALUE list form or the mapping form of
+// field, calling add for every entry in order. Errors wrap kind. Entries
+// without a value go to addNull, or are empty values when it is nil.
+func decodeKeyValuesYAML(node *yaml.Node, field string, kind error, add func(key, value string), addNull func(key string)) error {
+	if node.Kind == yaml.SequenceNode {
+		for _, item := range node.Content {
+			match := regEnv.FindStringSubmatch(item.Value)
+			switch {
+			case match != nil:
+				add(match[1], match[2])
+			case addNull != nil && item.Kind == yaml.ScalarNode && item.Value != "":
+				addNull(item.Value)
+			default:
+				return newParseError(item, field, "invalid %s format: %s", field, item.Value).wrapping(kind)
+			}
+		}
+		return nil
+	}
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if addNull != nil && value.Tag == "!!null" {
+				addNull(key.Value)
+				continue
+			}
+			add(key.Value, value.Value)
+		}
+		return nil
+	}
+	return newParseError(node, field, "invalid %s format", field).wrapping(kind)
+}
+
+// encodeKeyValuesYAML emits the mapping form in the order of keys, with
+// nulls written as null. Each scalar goes through the encoder on its own so
+// values such as "on" or "0755" stay quoted.
+func encodeKeyValuesYAML(keys []string, values map[string]string, nulls map[string]bool) (*yaml.Node, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, key := range keys {
+		keyNode, valueNode := &yaml.Node{}, &yaml.Node{}
+		if err := keyNode.Encode(key); err != nil {
+			return nil, err
+		}
+		var value any = values[key]
+		if nulls[key] {
+			value = nil
+		}
+		if err := valueNode.Encode(value); err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, keyNode, valueNode)
+	}
+	return node, nil
+}
+
+func decodeKeyValuesJSON(data []byte, field string, kind error, add func(key, value string), addNull func(key string)) error {
+	var items []string
+	if err := jsoniter.Unmarshal(data, &items); err == nil {
+		for _, item := range items {
+			match := regEnv.FindStringSubmatch(item)
+			switch {
+			case match != nil:
+				add(match[1], match[2])
+			case addNull != nil && item != "":
+				addNull(item)
+			default:
+				return fmt.Errorf("%w format: %s", kind, item)
+			}
+		}
+		return nil
+	}
+	iter := jsoniter.ConfigCompatibleWithStandardLibrary.BorrowIterator(data)
+	defer jsoniter.ConfigCompatibleWithStandardLibrary.ReturnIterator(iter)
+	iter.ReadObjectCB(func(iter *jsoniter.Iterator, key string) bool {
+		if iter.WhatIsNext() == jsoniter.NilValue {
+			iter.Skip()
+			if addNull != nil {
+				addNull(key)
+			} else {
+				add(key, "")
+			}
+			return true
+		}
+		add(key, iter.ReadAny().ToString())
+		return true
+	})
+	if iter.Error != nil {
+		return fmt.Errorf("%w format: %w", kind, iter.Error)
+	}
+	return nil
+}
+
+func encodeKeyValuesJSON(keys []string, values map[string]string, nulls map[string]bool) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		encodedKey, err := jsoniter.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		var value any = values[key]
+		if nulls[key] {
+			value = nil
+		}
+		encodedValue, err := jsoniter.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(encodedKey)
+		buf.WriteByte(':')
+		buf.Write(encodedValue)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (serviceConf *ComposeServiceConfig) SetEnv(key, value string) {
+	if serviceConf.Environment == nil {
+		serviceConf.Environment = &ComposeEnvironmentConfig{}
+	}
+	serviceConf.Environment.Set(key, value)
+}
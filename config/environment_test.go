@@ -0,0 +1,54 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExportEnvironmentListKeepsNulls(t *testing.T) {
+	conf := mustParse(t, `services:
+  web:
+    image: nginx
+    environment:
+      A: "1"
+      DEBUG: null
+`)
+	content, err := conf.ExportYAMLWithOptions(YAMLOptions{EnvironmentStyle: EnvironmentStyleList})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "DEBUG=") {
+		t.Fatalf("null variable written with a value:\n%s", content)
+	}
+	reloaded := mustParse(t, string(content))
+	env := (*reloaded.Services)["web"].Environment
+	if value, _ := env.Get("A"); value != "1" {
+		t.Errorf("A = %q, want 1", value)
+	}
+	if !env.IsNull("DEBUG") {
+		t.Errorf("DEBUG is not null after reload:\n%s", content)
+	}
+}
+
+func TestMergeNullEnvironmentRemovesBaseKey(t *testing.T) {
+	base := mustParse(t, `services:
+  web:
+    image: nginx
+    environment:
+      KEEP: "1"
+      DROP: "2"
+`)
+	override := mustParse(t, `services:
+  web:
+    environment:
+      DROP: null
+`)
+	base.Merge(override)
+	env := (*base.Services)["web"].Environment
+	if _, ok := env.Get("DROP"); ok {
+		t.Error("DROP is still set after a null override")
+	}
+	if value, _ := env.Get("KEEP"); value != "1" {
+		t.Errorf("KEEP = %q, want 1", value)
+	}
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"strings"
+)
+
+// Errors returned by this package wrap one of these when they fall into the
+// category, so callers can tell them apart with errors.Is. JSON decoding
+// errors are the exception: the JSON decoder flattens them to text.
+var (
+	ErrUnsupportedFormat  = errors.New("unsupported compose file format")
+	ErrInvalidDependsOn   = errors.New("invalid depends_on")
+	ErrInvalidEnvironment = errors.New("invalid environment")
+	ErrInvalidAnnotations = errors.New("invalid annotations")
+	ErrServiceNotFound    = errors.New("service not found")
+)
+
+type ParseError struct {
+	File   string
+	Line   int
+	Column int
+	Field  string
+	Msg    string
+	// Err is the category of the error, such as ErrInvalidDependsOn, when it
+	// has one.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	location := e.File
+	if e.Line > 0 {
+		if location != "" {
+			location += ":"
+		}
+		location += fmt.Sprintf("%d:%d", e.Line, e.Column)
+	}
+	message := e.Msg
+	if e.Field != "" {
+		message = fmt.Sprintf("%s: %s", e.Field, message)
+	}
+	if location == "" {
+		return message
+	}
+	return fmt.Sprintf("%s: %s", location, message)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// wrapping sets the category of the error.
+func (e *ParseError) wrapping(err error) *ParseError {
+	e.Err = err
+	return e
+}
+
+func newParseError(node *yaml.Node, field string, format string, args ...any) *ParseError {
+	return &ParseError{
+		Line:   node.Line,
+		Column: node.Column,
+		Field:  field,
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+type MultiError struct {
+	Errors []error
+}
+
+func (e *MultiError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+func (e *MultiError) Unwrap() []error {
+	return e.Errors
+}
+
+// Append adds err to the list, flattening nested MultiErrors and skipping nil.
+func (e *MultiError) Append(err error) {
+	if err == nil {
+		return
+	}
+	if multiErr, ok := err.(*MultiError); ok {
+		e.Errors = append(e.Errors, multiErr.Errors...)
+		return
+	}
+	e.Errors = append(e.Errors, err)
+}
+
+func (e *MultiError) ErrorOrNil() error {
+	if e == nil || len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
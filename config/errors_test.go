@@ -0,0 +1,63 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestErrorsWrapTheirCategory(t *testing.T) {
+	parse := func(data string) func() error {
+		return func() error {
+			_, err := GetConfigFromBytes([]byte(data), FormatYAML)
+			return err
+		}
+	}
+	conf := mustParse(t, "services:\n  web:\n    image: nginx\n")
+	tests := []struct {
+		name       string
+		run        func() error
+		want       error
+		parseError bool
+	}{
+		{"unknown format", func() error {
+			_, err := GetConfigFromBytes([]byte("{}"), Format(42))
+			return err
+		}, ErrUnsupportedFormat, false},
+		{"unknown extension", func() error {
+			return conf.WriteToFile(filepath.Join(t.TempDir(), "compose.toml"))
+		}, ErrUnsupportedFormat, false},
+		{"depends_on scalar", parse("services:\n  web:\n    image: nginx\n    depends_on: db\n"), ErrInvalidDependsOn, true},
+		{"depends_on JSON", func() error {
+			return (&ComposeDependsOnConfig{}).UnmarshalJSON([]byte(`"db"`))
+		}, ErrInvalidDependsOn, false},
+		{"environment scalar", parse("services:\n  web:\n    image: nginx\n    environment: A=1\n"), ErrInvalidEnvironment, true},
+		{"environment JSON", func() error {
+			return (&ComposeEnvironmentConfig{}).UnmarshalJSON([]byte(`"A=1"`))
+		}, ErrInvalidEnvironment, false},
+		{"annotations scalar", parse("services:\n  web:\n    image: nginx\n    annotations: a\n"), ErrInvalidAnnotations, true},
+		{"missing service", func() error {
+			_, err := conf.LookupService("db")
+			return err
+		}, ErrServiceNotFound, false},
+		{"missing lazy service", func() error {
+			lazy, err := GetLazyConfigFromBytes([]byte("services:\n  web:\n    image: nginx\n"))
+			if err != nil {
+				return err
+			}
+			_, err = lazy.GetService("db")
+			return err
+		}, ErrServiceNotFound, false},
+	}
+	for _, test := range tests {
+		err := test.run()
+		if !errors.Is(err, test.want) {
+			t.Errorf("%s: got %v, want it to wrap %v", test.name, err, test.want)
+			continue
+		}
+		var parseErr *ParseError
+		if test.parseError && (!errors.As(err, &parseErr) || parseErr.Line == 0) {
+			t.Errorf("%s: %v has no line", test.name, err)
+		}
+	}
+}
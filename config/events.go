@@ -0,0 +1,161 @@
+package config
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+var regEnvName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type EventLevel int
+
+const (
+	EventDebug EventLevel = iota
+	EventWarn
+)
+
+func (l EventLevel) String() string {
+	switch l {
+	case EventDebug:
+		return "debug"
+	case EventWarn:
+		return "warn"
+	default:
+		return fmt.Sprintf("EventLevel(%d)", int(l))
+	}
+}
+
+// Event is something ParseComposeWithOptions did that does not stop the
+// parse. Service is empty for events outside of services, and Field is the
+// dotted path below the service, or below the top of the file.
+type Event struct {
+	Level   EventLevel
+	Service string
+	Field   string
+	Message string
+}
+
+func (e Event) String() string {
+	if e.Service == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("service %s: %s", e.Service, e.Message)
+}
+
+var composeFields = yamlFields(reflect.TypeOf(ComposeConfig{}))
+
+func reportUnknownTopLevel(root *yaml.Node, report func(Event)) {
+	if root.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key := root.Content[i].Value
+		if !composeFields[key] && !isMergeKey(root.Content[i]) && !strings.HasPrefix(key, "x-") {
+			report(Event{Level: EventWarn, Field: key, Message: fmt.Sprintf("unknown field '%s' ignored", key)})
+		}
+	}
+}
+
+// interpolateNode interpolates the scalar values under node, reporting every
+// value that changed. path holds the keys leading to node.
+func interpolateNode(node *yaml.Node, path []string, lookup func(string) (string, bool), report func(Event)) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		value, err := InterpolateString(node.Value, lookup)
+		if err != nil {
+			return newParseError(node, strings.Join(path, "."), "%v", err)
+		}
+		vars := []string{}
+		scanVars(node.Value, func(name string, _ bool) {
+			vars = append(vars, name)
+		})
+		if len(vars) == 0 {
+			node.Value = value
+			return nil
+		}
+		event := Event{Level: EventDebug, Field: strings.Join(path, ".")}
+		if len(path) > 2 && path[0] == "services" {
+			event.Service, event.Field = path[1], strings.Join(path[2:], ".")
+		}
+		event.Message = fmt.Sprintf("%s interpolated from %s", event.Field, strings.Join(vars, ", "))
+		report(event)
+		node.Value = value
+		// a plain scalar such as ${REPLICAS} resolved to !!str before it was
+		// substituted; let the decoder resolve the substituted value again
+		if node.Style&(yaml.TaggedStyle|yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+			node.Tag = ""
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if err := interpolateNode(node.Content[i+1], append(path[:len(path):len(path)], node.Content[i].Value), lookup, report); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			if err := interpolateNode(child, append(path[:len(path):len(path)], fmt.Sprint(i)), lookup, report); err != nil {
+				return err
+			}
+		}
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			if err := interpolateNode(child, path, lookup, report); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// reportDuplicateEnvironment reports the variables a service environment
+// defines more than once, with the line of every occurrence. Decoding keeps
+// the last value without complaint.
+func reportDuplicateEnvironment(root *yaml.Node, report func(Event)) {
+	services := mappingValue(root, "services")
+	if services == nil || services.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(services.Content); i += 2 {
+		serviceName, service := services.Content[i].Value, services.Content[i+1]
+		if service.Kind == yaml.AliasNode {
+			service = service.Alias
+		}
+		environment := mappingValue(service, "environment")
+		if environment == nil {
+			continue
+		}
+		if environment.Kind == yaml.AliasNode {
+			environment = environment.Alias
+		}
+		lines := map[string][]string{}
+		keys := []string{}
+		add := func(key string, line int) {
+			if _, ok := lines[key]; !ok {
+				keys = append(keys, key)
+			}
+			lines[key] = append(lines[key], fmt.Sprint(line))
+		}
+		switch environment.Kind {
+		case yaml.MappingNode:
+			for j := 0; j+1 < len(environment.Content); j += 2 {
+				if key := environment.Content[j]; !isMergeKey(key) {
+					add(key.Value, key.Line)
+				}
+			}
+		case yaml.SequenceNode:
+			for _, item := range environment.Content {
+				key, _, _ := strings.Cut(item.Value, "=")
+				add(key, item.Line)
+			}
+		}
+		for _, key := range keys {
+			if len(lines[key]) > 1 {
+				report(Event{Level: EventWarn, Service: serviceName, Field: joinPath("environment", key),
+					Message: fmt.Sprintf("environment variable %s defined more than once, on lines %s; the last one wins", key, strings.Join(lines[key], ", "))})
+			}
+		}
+	}
+}
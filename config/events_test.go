@@ -0,0 +1,39 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestParseComposeWithOptionsInterpolatesTypedFields(t *testing.T) {
+	data := []byte(`services:
+  web:
+    image: nginx:${TAG}
+    scale: ${N}
+    privileged: ${P}
+    hostname: "${N}"
+`)
+	env := map[string]string{"TAG": "1.25", "N": "3", "P": "true"}
+	conf, err := ParseComposeWithOptions(data, ParseOptions{
+		Format: FormatYAML,
+		Lookup: func(name string) (string, bool) {
+			value, ok := env[name]
+			return value, ok
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	web := (*conf.Services)["web"]
+	if web.Image != "nginx:1.25" {
+		t.Errorf("image = %q, want nginx:1.25", web.Image)
+	}
+	if web.Scale == nil || *web.Scale != 3 {
+		t.Errorf("scale = %v, want 3", web.Scale)
+	}
+	if !web.IsPrivileged() {
+		t.Error("privileged = false, want true")
+	}
+	if web.Hostname != "3" {
+		t.Errorf("hostname = %q, want 3", web.Hostname)
+	}
+}
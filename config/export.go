@@ -0,0 +1,426 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// sortedJSON mirrors jsoniter.ConfigCompatibleWithStandardLibrary so that map
+// keys come out sorted and exports are stable across runs.
+var sortedJSON = jsoniter.Config{
+	EscapeHTML:             true,
+	SortMapKeys:            true,
+	ValidateJsonRawMessage: true,
+}.Froze()
+
+type writeOptions struct {
+	indent int
+}
+
+type WriteOption func(opts *writeOptions)
+
+func WithIndent(indent int) WriteOption {
+	return func(opts *writeOptions) {
+		opts.indent = indent
+	}
+}
+
+// WriteToFile writes the config to a temporary file next to path and renames
+// it over the target, so readers never observe a partially written file.
+// Services are always written sorted by name, as the other exports do.
+func (conf *ComposeConfig) WriteToFile(path string, opts ...WriteOption) error {
+	options := &writeOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	var content []byte
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		content, err = conf.exportYAML(options.indent)
+	case ".json":
+		content, err = conf.exportJSON(options.indent)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedFormat, filepath.Ext(path))
+	}
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, content)
+}
+
+// WriteFileAtomic replaces the file at path with content through a temporary
+// file renamed over it, keeping the permissions of the file it replaces.
+func WriteFileAtomic(path string, content []byte) error {
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err = tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+func (conf *ComposeConfig) exportYAML(indent int) ([]byte, error) {
+	if indent <= 0 {
+		return conf.ExportYAML()
+	}
+	return conf.ExportYAMLWithOptions(YAMLOptions{Indent: indent})
+}
+
+type Style int
+
+const (
+	StyleDefault Style = iota
+	StyleFlow
+	StyleBlock
+	// StyleAuto uses flow style for sequences of scalars that fit on one line
+	// within YAMLOptions.Width, block style otherwise.
+	StyleAuto
+)
+
+type YAMLOptions struct {
+	// Indent defaults to the encoder's 4 spaces when zero.
+	Indent int
+	// SequenceStyle sets the style of every sequence. StyleDefault keeps the
+	// encoder's choice: flow for healthcheck tests, block for the rest.
+	SequenceStyle Style
+	// FieldStyles overrides SequenceStyle for the sequences under the given
+	// keys, such as "ports", "volumes" or "test".
+	FieldStyles map[string]Style
+	// Width is the line length StyleAuto keeps flow sequences under; 80 when
+	// zero.
+	Width int
+	// RawScalars leaves strings in the encoder's style. By default strings
+	// that a YAML 1.1 or 1.2 parser could read back as another type, such as
+	// on, yes, 3.8, 0755 or 8080:80, are double-quoted.
+	RawScalars bool
+	// HeaderComment is emitted as a comment block at the top of the document.
+	HeaderComment string
+	// EnvironmentStyle selects the mapping or KEY=VALUE list form of service
+	// environments. Both forms follow ComposeEnvironmentConfig.Keys.
+	EnvironmentStyle EnvironmentStyle
+	// DeduplicateWithAnchors emits healthcheck and environment blocks shared
+	// by several services once, under an anchor the other services alias.
+	DeduplicateWithAnchors bool
+}
+
+type EnvironmentStyle int
+
+const (
+	EnvironmentStyleMap EnvironmentStyle = iota
+	EnvironmentStyleList
+)
+
+func (conf *ComposeConfig) ExportYAMLWithOptions(opts YAMLOptions) ([]byte, error) {
+	root := &yaml.Node{}
+	if err := root.Encode(conf); err != nil {
+		return nil, err
+	}
+	if opts.EnvironmentStyle == EnvironmentStyleList {
+		forEachServiceField(root, "environment", environmentToList)
+	}
+	applyYAMLOptions(root, opts)
+	if opts.DeduplicateWithAnchors {
+		anchors := map[string]bool{}
+		for _, field := range []string{"healthcheck", "environment"} {
+			deduplicateServiceField(root, field, anchors)
+		}
+	}
+	return encodeYAMLDocument(root, opts)
+}
+
+func encodeYAMLDocument(root *yaml.Node, opts YAMLOptions) ([]byte, error) {
+	doc := &yaml.Node{
+		Kind:        yaml.DocumentNode,
+		Content:     []*yaml.Node{root},
+		HeadComment: opts.HeaderComment,
+	}
+
+	buf := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(buf)
+	if opts.Indent > 0 {
+		encoder.SetIndent(opts.Indent)
+	}
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type ServiceOrder int
+
+const (
+	ServiceOrderAlphabetical ServiceOrder = iota
+	ServiceOrderDependency
+)
+
+// ExportYAMLOrdered exports the config with services in the given order.
+// Dependency order breaks ties alphabetically; when the dependency graph has
+// a cycle or an undefined service the export falls back to alphabetical order
+// and says so in the returned warnings.
+func (conf *ComposeConfig) ExportYAMLOrdered(order ServiceOrder) ([]byte, []Warning, error) {
+	root := &yaml.Node{}
+	if err := root.Encode(conf); err != nil {
+		return nil, nil, err
+	}
+	warnings := []Warning{}
+	if order == ServiceOrderDependency {
+		names, err := conf.DependencyOrder()
+		if err != nil {
+			warnings = append(warnings, Warning{Path: "services", Message: fmt.Sprintf("keeping alphabetical service order: %v", err)})
+		} else if services := mappingValue(root, "services"); services != nil {
+			sortMappingKeys(services, indexKeys(names))
+		}
+	}
+	opts := YAMLOptions{}
+	applyYAMLOptions(root, opts)
+	content, err := encodeYAMLDocument(root, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return content, warnings, nil
+}
+
+func applyYAMLOptions(node *yaml.Node, opts YAMLOptions) {
+	applyNodeOptions(node, opts, "", 0)
+}
+
+func applyNodeOptions(node *yaml.Node, opts YAMLOptions, key string, depth int) {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		style, ok := opts.FieldStyles[key]
+		if !ok {
+			style = opts.SequenceStyle
+		}
+		switch style {
+		case StyleFlow:
+			node.Style |= yaml.FlowStyle
+		case StyleBlock:
+			node.Style &^= yaml.FlowStyle
+		case StyleAuto:
+			if fitsFlowStyle(node, opts, key, depth) {
+				node.Style |= yaml.FlowStyle
+			} else {
+				node.Style &^= yaml.FlowStyle
+			}
+		}
+	case yaml.ScalarNode:
+		if !opts.RawScalars && node.Tag == "!!str" && isAmbiguousScalar(node.Value) {
+			node.Style = yaml.DoubleQuotedStyle
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			applyNodeOptions(node.Content[i], opts, "", depth+1)
+			applyNodeOptions(node.Content[i+1], opts, node.Content[i].Value, depth+1)
+		}
+		return
+	}
+	for _, child := range node.Content {
+		applyNodeOptions(child, opts, key, depth+1)
+	}
+}
+
+// fitsFlowStyle reports whether the sequence holds only scalars and renders
+// as "key: [a, b]" within the configured width at its nesting depth.
+func fitsFlowStyle(node *yaml.Node, opts YAMLOptions, key string, depth int) bool {
+	width, indent := opts.Width, opts.Indent
+	if width <= 0 {
+		width = 80
+	}
+	if indent <= 0 {
+		indent = 4
+	}
+	for _, item := range node.Content {
+		if item.Kind != yaml.ScalarNode {
+			return false
+		}
+	}
+	flow := *node
+	flow.Style |= yaml.FlowStyle
+	rendered, err := yaml.Marshal(&flow)
+	if err != nil {
+		return false
+	}
+	line := strings.TrimSuffix(string(rendered), "\n")
+	if strings.Contains(line, "\n") {
+		return false
+	}
+	// mapping depth 1 is the top level, which is not indented
+	return (depth-1)*indent+len(key)+2+len(line) <= width
+}
+
+// forEachServiceField calls fn with the value node of field in every service
+// of an encoded config.
+func forEachServiceField(root *yaml.Node, field string, fn func(node *yaml.Node)) {
+	services := mappingValue(root, "services")
+	if services == nil || services.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 1; i < len(services.Content); i += 2 {
+		if value := mappingValue(services.Content[i], field); value != nil {
+			fn(value)
+		}
+	}
+}
+
+// deduplicateServiceField anchors the first of every group of identical field
+// values and turns the others into aliases of it. anchors holds the names
+// already taken in the document.
+func deduplicateServiceField(root *yaml.Node, field string, anchors map[string]bool) {
+	services := mappingValue(root, "services")
+	if services == nil || services.Kind != yaml.MappingNode {
+		return
+	}
+	first := map[string]*yaml.Node{}
+	owner := map[*yaml.Node]string{}
+	for i := 0; i+1 < len(services.Content); i += 2 {
+		value := mappingValue(services.Content[i+1], field)
+		if value == nil || value.Kind == yaml.ScalarNode {
+			continue
+		}
+		content, err := yaml.Marshal(value)
+		if err != nil {
+			continue
+		}
+		anchored, ok := first[string(content)]
+		if !ok {
+			first[string(content)] = value
+			owner[value] = services.Content[i].Value
+			continue
+		}
+		if anchored.Anchor == "" {
+			anchored.Anchor = uniqueAnchor(field+"-"+owner[anchored], anchors)
+		}
+		*value = yaml.Node{Kind: yaml.AliasNode, Value: anchored.Anchor, Alias: anchored}
+	}
+}
+
+// uniqueAnchor turns name into a valid anchor that is not in anchors yet.
+func uniqueAnchor(name string, anchors map[string]bool) string {
+	anchor := strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, name)
+	for i, base := 2, anchor; anchors[anchor]; i++ {
+		anchor = fmt.Sprintf("%s_%d", base, i)
+	}
+	anchors[anchor] = true
+	return anchor
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func environmentToList(node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	items := make([]*yaml.Node, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		item := key.Value
+		// null variables are unset, which the list form writes as a bare KEY
+		if value.Tag != "!!null" {
+			item += "=" + value.Value
+		}
+		items = append(items, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: item})
+	}
+	node.Kind = yaml.SequenceNode
+	node.Tag = "!!seq"
+	node.Style = 0
+	node.Content = items
+}
+
+var (
+	regYAML11Bool   = regexp.MustCompile(`^(?:y|Y|yes|Yes|YES|n|N|no|No|NO|true|True|TRUE|false|False|FALSE|on|On|ON|off|Off|OFF)$`)
+	regYAML11Num    = regexp.MustCompile(`^[-+]?(?:0b[01_]+|0[0-7_]+|0x[0-9a-fA-F_]+|[0-9][0-9_]*(?:\.[0-9_]*)?(?:[eE][-+]?[0-9]+)?|\.[0-9_]+|\.(?:inf|Inf|INF)|\.(?:nan|NaN|NAN))$`)
+	regYAMLColonNum = regexp.MustCompile(`^[-+]?[0-9][0-9_]*(?::[0-9][0-9_]*)+(?:\.[0-9_]*)?$`)
+)
+
+// isAmbiguousScalar reports whether an unquoted s would not be read back as
+// the same string by YAML 1.1 or 1.2 parsers.
+func isAmbiguousScalar(s string) bool {
+	if s == "" || s == "~" || strings.EqualFold(s, "null") {
+		return true
+	}
+	if regYAML11Bool.MatchString(s) || regYAML11Num.MatchString(s) || regYAMLColonNum.MatchString(s) {
+		return true
+	}
+	var value any
+	if err := yaml.Unmarshal([]byte(s), &value); err != nil {
+		return true
+	}
+	_, ok := value.(string)
+	return !ok
+}
+
+func (conf *ComposeConfig) exportJSON(indent int) ([]byte, error) {
+	if indent <= 0 {
+		return conf.ExportJSON()
+	}
+	return conf.ExportJSONIndent("", strings.Repeat(" ", indent))
+}
+
+func (conf *ComposeConfig) ExportJSONIndent(prefix, indent string) ([]byte, error) {
+	content, err := conf.ExportJSON()
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	if err = json.Indent(buf, content, prefix, indent); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
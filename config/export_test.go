@@ -0,0 +1,141 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+)
+
+const unorderedCompose = `services:
+  web:
+    image: nginx
+    labels: {z: "1", a: "2", m: "3"}
+    networks: [front, back]
+  db:
+    image: postgres
+networks:
+  front: {driver_opts: {z: a, b: c}}
+  back: {}
+  admin: {}
+volumes:
+  v9: {}
+  v1: {}
+  v5: {driver_opts: {o: a, t: b}}
+secrets:
+  s9: {file: a}
+  s1: {file: b}
+  s5: {file: c}
+`
+
+func mustParse(t testing.TB, data string) *ComposeConfig {
+	t.Helper()
+	conf, err := ParseComposeWithOptions([]byte(data), ParseOptions{Format: FormatYAML})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conf
+}
+
+func TestExportYAMLIsDeterministic(t *testing.T) {
+	conf := mustParse(t, unorderedCompose)
+	first, err := conf.ExportYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		content, err := conf.ExportYAML()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(content, first) {
+			t.Fatalf("export %d differs:\n%s\nfirst:\n%s", i, content, first)
+		}
+	}
+	for _, keys := range [][]string{{"admin:", "back:", "front:"}, {"v1:", "v5:", "v9:"}, {"s1:", "s5:", "s9:"}} {
+		last := -1
+		for _, key := range keys {
+			i := bytes.Index(first, []byte(key))
+			if i < last {
+				t.Errorf("%s is out of order in:\n%s", key, first)
+			}
+			last = i
+		}
+	}
+}
+
+const ambiguousCompose = `services:
+  web:
+    image: nginx
+    ports: ["8080:80"]
+    labels:
+      version: "3.10"
+      mode: "0755"
+      a: "yes"
+      b: "no"
+      c: "on"
+      d: "off"
+`
+
+func TestExportYAMLQuotesAmbiguousScalarsByDefault(t *testing.T) {
+	conf := mustParse(t, ambiguousCompose)
+	content, err := conf.ExportYAMLWithOptions(YAMLOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"8080:80"`, `"3.10"`, `"0755"`, `"yes"`, `"no"`, `"on"`, `"off"`} {
+		if !bytes.Contains(content, []byte(want)) {
+			t.Errorf("%s is not quoted in:\n%s", want, content)
+		}
+	}
+	labels := *(*mustParse(t, string(content)).Services)["web"].Labels
+	for key, value := range *(*conf.Services)["web"].Labels {
+		if labels[key] != value {
+			t.Errorf("label %s = %q after the round trip, want %q", key, labels[key], value)
+		}
+	}
+
+	raw, err := conf.ExportYAMLWithOptions(YAMLOptions{RawScalars: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(raw, []byte(`"8080:80"`)) {
+		t.Errorf("RawScalars quoted 8080:80:\n%s", raw)
+	}
+}
+
+func TestExportJSONIsDeterministic(t *testing.T) {
+	conf := mustParse(t, unorderedCompose)
+	first, err := conf.ExportJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		content, err := conf.ExportJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(content, first) {
+			t.Fatalf("export %d differs:\n%s\nfirst:\n%s", i, content, first)
+		}
+	}
+	// the service lists its networks too, take the top-level ones after it
+	networks := first[bytes.LastIndex(first, []byte(`"networks":`)):]
+	for _, order := range []struct {
+		content []byte
+		keys    []string
+	}{
+		{first, []string{`"a":"2"`, `"m":"3"`, `"z":"1"`}},
+		{networks, []string{`"admin":`, `"back":`, `"front":`}},
+		{first, []string{`"v1":`, `"v5":`, `"v9":`}},
+		{first, []string{`"s1":`, `"s5":`, `"s9":`}},
+		{first, []string{`"b":"c"`, `"z":"a"`}},
+	} {
+		last := -1
+		for _, key := range order.keys {
+			i := bytes.Index(order.content, []byte(key))
+			if i < 0 || i < last {
+				t.Errorf("%s is missing or out of order in:\n%s", key, first)
+			}
+			last = i
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Feature is a compose feature a config uses, with the legacy file format
+// versions that accept it. MinV2 and MinV3 are the first versions of the 2.x
+// and 3.x lines that do; each is empty when that line never did. Every
+// feature is accepted by the compose spec, i.e. files without a version.
+type Feature struct {
+	Name string
+	// Service is empty for top-level features.
+	Service string
+	MinV2   string
+	MinV3   string
+}
+
+// Requires describes the versions accepting the feature, such as
+// "2.x (2.1+), 3.x (3.0+) or the compose spec".
+func (f Feature) Requires() string {
+	versions := []string{}
+	for i, min := range []string{f.MinV2, f.MinV3} {
+		if min != "" {
+			versions = append(versions, fmt.Sprintf("%d.x (%s+)", i+2, min))
+		}
+	}
+	versions = append(versions, "the compose spec")
+	if len(versions) == 1 {
+		return versions[0]
+	}
+	return strings.Join(versions[:len(versions)-1], ", ") + " or " + versions[len(versions)-1]
+}
+
+// SupportedBy reports whether a file declaring version accepts the feature.
+// An empty version stands for the compose spec.
+func (f Feature) SupportedBy(version string) (bool, error) {
+	if version == "" {
+		return true, nil
+	}
+	major, minor, err := parseFileFormatVersion(version)
+	if err != nil {
+		return false, err
+	}
+	min := f.MinV2
+	if major == 3 {
+		min = f.MinV3
+	}
+	if min == "" {
+		return false, nil
+	}
+	_, minMinor, _ := parseFileFormatVersion(min)
+	return minor >= minMinor, nil
+}
+
+func parseFileFormatVersion(version string) (major, minor int, err error) {
+	majorStr, minorStr, hasMinor := strings.Cut(version, ".")
+	major, err = strconv.Atoi(majorStr)
+	if err == nil && hasMinor {
+		minor, err = strconv.Atoi(minorStr)
+	}
+	if err != nil || major < 2 || major > 3 || minor < 0 {
+		return 0, 0, fmt.Errorf("%w version %q", ErrUnsupportedFormat, version)
+	}
+	return major, minor, nil
+}
+
+type serviceFeature struct {
+	name         string
+	minV2, minV3 string
+	used         func(serviceConf *ComposeServiceConfig) bool
+}
+
+func rawKey(key string) func(serviceConf *ComposeServiceConfig) bool {
+	return func(serviceConf *ComposeServiceConfig) bool {
+		_, ok := serviceConf.RawExtra[key]
+		return ok
+	}
+}
+
+var serviceFeatures = []serviceFeature{
+	{"blkio_config", "2.2", "", func(s *ComposeServiceConfig) bool { return s.BlkioConfig != nil }},
+	{"cpu_count", "2.2", "", func(s *ComposeServiceConfig) bool { return s.CPUCount != nil }},
+	{"cpu_percent", "2.2", "", func(s *ComposeServiceConfig) bool { return s.CPUPercent != nil }},
+	{"depends_on.condition", "2.1", "", func(s *ComposeServiceConfig) bool {
+		if s.DependsOn != nil {
+			for _, dep := range *s.DependsOn {
+				if dep.Condition != "" {
+					return true
+				}
+			}
+		}
+		return false
+	}},
+	{"deploy", "", "3.0", func(s *ComposeServiceConfig) bool { return s.Deploy != nil }},
+	{"deploy.resources.reservations.devices", "", "", func(s *ComposeServiceConfig) bool {
+		return s.Deploy != nil && s.Deploy.Resources != nil && s.Deploy.Resources.Reservations != nil &&
+			len(s.Deploy.Resources.Reservations.Devices) > 0
+	}},
+	{"env_file.required", "", "", func(s *ComposeServiceConfig) bool {
+		for _, envFile := range s.EnvFile {
+			if envFile.Required != nil {
+				return true
+			}
+		}
+		return false
+	}},
+	{"healthcheck", "2.1", "3.0", func(s *ComposeServiceConfig) bool { return s.Healthcheck != nil }},
+	{"healthcheck.start_period", "2.3", "3.4", func(s *ComposeServiceConfig) bool {
+		return s.Healthcheck != nil && s.Healthcheck.StartPeriod != ""
+	}},
+	{"init", "2.2", "3.7", rawKey("init")},
+	{"isolation", "2.1", "3.5", func(s *ComposeServiceConfig) bool { return s.Isolation != "" }},
+	{"mem_limit", "2.0", "", func(s *ComposeServiceConfig) bool { return s.MemLimit != "" }},
+	{"mem_reservation", "2.0", "", func(s *ComposeServiceConfig) bool { return s.MemReservation != "" }},
+	{"oom_score_adj", "2.0", "", func(s *ComposeServiceConfig) bool { return s.OOMScoreAdj != nil }},
+	{"ports.long_syntax", "", "3.2", func(s *ComposeServiceConfig) bool {
+		for _, port := range s.Ports {
+			if port.Long != nil {
+				return true
+			}
+		}
+		return false
+	}},
+	{"profiles", "", "", rawKey("profiles")},
+	{"pull_policy", "", "", func(s *ComposeServiceConfig) bool { return s.PullPolicy != "" }},
+	{"runtime", "2.3", "", func(s *ComposeServiceConfig) bool { return s.Runtime != "" }},
+	{"scale", "2.2", "", func(s *ComposeServiceConfig) bool { return s.Scale != nil }},
+	{"secrets", "", "3.1", func(s *ComposeServiceConfig) bool { return len(s.Secrets) > 0 }},
+	{"userns_mode", "2.1", "3.0", func(s *ComposeServiceConfig) bool { return s.UsernsMode != "" }},
+	{"volumes.long_syntax", "2.3", "3.2", func(s *ComposeServiceConfig) bool {
+		for _, volume := range s.Volumes {
+			if volume.Long != nil {
+				return true
+			}
+		}
+		return false
+	}},
+}
+
+// DetectFeatures lists the version-dependent features the config uses,
+// top-level ones first, then by service and name.
+func (conf *ComposeConfig) DetectFeatures() []Feature {
+	features := []Feature{}
+	if len(conf.Include) > 0 {
+		features = append(features, Feature{Name: "include"})
+	}
+	if len(conf.Configs) > 0 {
+		features = append(features, Feature{Name: "configs", MinV3: "3.3"})
+	}
+	for _, name := range sortedKeys(conf.Networks) {
+		if networkConf := conf.Networks[name]; networkConf != nil && networkConf.Name != "" {
+			features = append(features, Feature{Name: "networks.name", MinV2: "2.1", MinV3: "3.5"})
+			break
+		}
+	}
+	if len(conf.Secrets) > 0 {
+		features = append(features, Feature{Name: "secrets", MinV3: "3.1"})
+	}
+	for _, name := range sortedKeys(conf.Volumes) {
+		if volumeConf := conf.Volumes[name]; volumeConf != nil && volumeConf.Name != "" {
+			features = append(features, Feature{Name: "volumes.name", MinV2: "2.1", MinV3: "3.4"})
+			break
+		}
+	}
+	sort.SliceStable(features, func(i, j int) bool {
+		return features[i].Name < features[j].Name
+	})
+
+	for _, service := range conf.ServiceNames() {
+		serviceConf := (*conf.Services)[service]
+		for _, feature := range serviceFeatures {
+			if feature.used(serviceConf) {
+				features = append(features, Feature{
+					Name:    feature.name,
+					Service: service,
+					MinV2:   feature.minV2,
+					MinV3:   feature.minV3,
+				})
+			}
+		}
+	}
+	return features
+}
+
+// CheckVersionCompatibility reports every feature the declared Version does
+// not accept. Files without a version follow the compose spec and always
+// pass.
+func (conf *ComposeConfig) CheckVersionCompatibility() []error {
+	errs := []error{}
+	if conf.Version == "" {
+		return errs
+	}
+	if _, _, err := parseFileFormatVersion(conf.Version); err != nil {
+		return append(errs, err)
+	}
+	for _, feature := range conf.DetectFeatures() {
+		if ok, _ := feature.SupportedBy(conf.Version); ok {
+			continue
+		}
+		err := fmt.Errorf("%s requires %s, the file declares version %s", feature.Name, feature.Requires(), conf.Version)
+		if feature.Service != "" {
+			err = fmt.Errorf("service %s: %w", feature.Service, err)
+		}
+		errs = append(errs, err)
+	}
+	return errs
+}
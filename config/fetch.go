@@ -0,0 +1,144 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+const defaultFetchMaxSize = 10 << 20
+
+type fetchOptions struct {
+	client   *http.Client
+	header   http.Header
+	maxSize  int64
+	format   Format
+	username string
+	password string
+	basic    bool
+}
+
+type FetchOption func(opts *fetchOptions)
+
+func WithHTTPClient(client *http.Client) FetchOption {
+	return func(opts *fetchOptions) {
+		opts.client = client
+	}
+}
+
+func WithBearerToken(token string) FetchOption {
+	return func(opts *fetchOptions) {
+		opts.header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+func WithBasicAuth(username, password string) FetchOption {
+	return func(opts *fetchOptions) {
+		opts.username = username
+		opts.password = password
+		opts.basic = true
+	}
+}
+
+func WithHeader(key, value string) FetchOption {
+	return func(opts *fetchOptions) {
+		opts.header.Add(key, value)
+	}
+}
+
+// WithMaxSize limits the response body size, 10MiB by default.
+func WithMaxSize(size int64) FetchOption {
+	return func(opts *fetchOptions) {
+		opts.maxSize = size
+	}
+}
+
+// WithFormat skips content-type and extension detection.
+func WithFormat(format Format) FetchOption {
+	return func(opts *fetchOptions) {
+		opts.format = format
+	}
+}
+
+func GetConfigFromURL(ctx context.Context, rawURL string, opts ...FetchOption) (*ComposeConfig, error) {
+	options := &fetchOptions{
+		client:  http.DefaultClient,
+		header:  http.Header{},
+		maxSize: defaultFetchMaxSize,
+		format:  FormatAuto,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported url scheme: %s", u.Scheme)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range options.header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	if options.basic {
+		req.SetBasicAuth(options.username, options.password)
+	}
+
+	resp, err := options.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s: unexpected status %s", rawURL, resp.Status)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, options.maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > options.maxSize {
+		return nil, fmt.Errorf("%s: response exceeds %d bytes", rawURL, options.maxSize)
+	}
+
+	format := options.format
+	if format == FormatAuto {
+		format = detectRemoteFormat(resp.Header.Get("Content-Type"), u.Path)
+	}
+	config, err := GetConfigFromBytes(content, format)
+	if err != nil {
+		return nil, withSource(err, rawURL)
+	}
+	return config, nil
+}
+
+func detectRemoteFormat(contentType string, urlPath string) Format {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		switch {
+		case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+			return FormatJSON
+		case strings.Contains(mediaType, "yaml"):
+			return FormatYAML
+		}
+	}
+	switch path.Ext(urlPath) {
+	case ".json":
+		return FormatJSON
+	case ".yml", ".yaml":
+		return FormatYAML
+	}
+	return FormatAuto
+}
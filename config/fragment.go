@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"strings"
+)
+
+// fragmentMarker starts a string value standing for the content of a
+// fragment file, as in "!file ./healthcheck.yml". The same file can be
+// referenced with the YAML tag form, !file ./healthcheck.yml, unquoted.
+const fragmentMarker = "!file"
+
+// DirFragmentLoader returns a FragmentLoader reading fragment paths relative
+// to dir.
+func DirFragmentLoader(dir string) func(path string) ([]byte, error) {
+	return func(path string) ([]byte, error) {
+		return os.ReadFile(absHostPath(dir, path))
+	}
+}
+
+func fragmentPath(node *yaml.Node) (string, bool) {
+	if node.Kind != yaml.ScalarNode {
+		return "", false
+	}
+	if node.Tag == fragmentMarker {
+		return node.Value, true
+	}
+	if node.Tag != "!!str" {
+		return "", false
+	}
+	path, ok := strings.CutPrefix(node.Value, fragmentMarker+" ")
+	return strings.TrimSpace(path), ok
+}
+
+// inlineFragments replaces the fragment markers among the values under node
+// with the fragments they reference. chain holds the fragments being inlined,
+// to catch fragments that end up including themselves.
+func inlineFragments(node *yaml.Node, load func(path string) ([]byte, error), chain []string) error {
+	if path, ok := fragmentPath(node); ok {
+		for _, parent := range chain {
+			if parent == path {
+				return newParseError(node, "", "fragment cycle: %s", strings.Join(append(chain, path), " -> "))
+			}
+		}
+		content, err := load(path)
+		if err != nil {
+			return newParseError(node, "", "loading fragment %s: %v", path, err).wrapping(err)
+		}
+		fragment := &yaml.Node{}
+		if err = yaml.Unmarshal(content, fragment); err != nil {
+			return fmt.Errorf("fragment %s: %w", path, err)
+		}
+		if len(fragment.Content) == 0 {
+			return newParseError(node, "", "fragment %s is empty", path)
+		}
+		if err = inlineFragments(fragment.Content[0], load, append(chain, path)); err != nil {
+			return err
+		}
+		anchor := node.Anchor
+		*node = *fragment.Content[0]
+		if anchor != "" {
+			node.Anchor = anchor
+		}
+		return nil
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := inlineFragments(child, load, chain); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		// only values, a fragment cannot stand for a key
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := inlineFragments(node.Content[i], load, chain); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,351 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// dependencies returns the sorted names each service depends on.
+func (conf *ComposeConfig) dependencies() map[string][]string {
+	graph := map[string][]string{}
+	for _, name := range conf.ServiceNames() {
+		serviceConf := (*conf.Services)[name]
+		deps := []string{}
+		if serviceConf.DependsOn != nil {
+			deps = sortedKeys(*serviceConf.DependsOn)
+		}
+		graph[name] = deps
+	}
+	return graph
+}
+
+// SuggestNetworkTopology proposes one network per service that others depend
+// on, joined by that service and its direct dependents only.
+func (conf *ComposeConfig) SuggestNetworkTopology() map[string][]string {
+	graph := conf.dependencies()
+	membership := map[string]map[string]bool{}
+	join := func(service, network string) {
+		if membership[service] == nil {
+			membership[service] = map[string]bool{}
+		}
+		membership[service][network] = true
+	}
+	for service, deps := range graph {
+		for _, dep := range deps {
+			if _, ok := graph[dep]; !ok {
+				continue
+			}
+			network := fmt.Sprintf("%s_net", dep)
+			join(dep, network)
+			join(service, network)
+		}
+	}
+
+	result := map[string][]string{}
+	for service := range graph {
+		result[service] = sortedKeys(membership[service])
+	}
+	return result
+}
+
+// DependencyOrder returns service names so that every service comes after its
+// dependencies, breaking ties alphabetically.
+func (conf *ComposeConfig) DependencyOrder() ([]string, error) {
+	graph := conf.dependencies()
+	pending := map[string]int{}
+	dependents := map[string][]string{}
+	for _, service := range sortedKeys(graph) {
+		for _, dep := range graph[service] {
+			if _, ok := graph[dep]; !ok {
+				return nil, fmt.Errorf("%w: service %s depends on undefined service %s", ErrInvalidDependsOn, service, dep)
+			}
+			dependents[dep] = append(dependents[dep], service)
+		}
+		pending[service] = len(graph[service])
+	}
+
+	ready := []string{}
+	for _, service := range sortedKeys(graph) {
+		if pending[service] == 0 {
+			ready = append(ready, service)
+		}
+	}
+	order := make([]string, 0, len(graph))
+	for len(ready) > 0 {
+		service := ready[0]
+		ready = ready[1:]
+		order = append(order, service)
+		for _, dependent := range dependents[service] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				ready = append(ready, dependent)
+				sort.Strings(ready)
+			}
+		}
+	}
+
+	if len(order) < len(graph) {
+		cyclic := []string{}
+		for _, service := range sortedKeys(graph) {
+			if pending[service] > 0 {
+				cyclic = append(cyclic, service)
+			}
+		}
+		return nil, fmt.Errorf("%w: dependency cycle detected between services: %s", ErrInvalidDependsOn, strings.Join(cyclic, ", "))
+	}
+	return order, nil
+}
+
+type StartupDependency struct {
+	Service        string
+	WaitForHealthy bool
+}
+
+type StartupStep struct {
+	Service      string
+	Dependencies []StartupDependency
+}
+
+func (conf *ComposeConfig) StartupPlan() ([]StartupStep, error) {
+	order, err := conf.DependencyOrder()
+	if err != nil {
+		return nil, err
+	}
+	plan := make([]StartupStep, 0, len(order))
+	for _, service := range order {
+		step := StartupStep{
+			Service:      service,
+			Dependencies: []StartupDependency{},
+		}
+		if dependsOn := (*conf.Services)[service].DependsOn; dependsOn != nil {
+			for _, dep := range sortedKeys(*dependsOn) {
+				step.Dependencies = append(step.Dependencies, StartupDependency{
+					Service:        dep,
+					WaitForHealthy: (*dependsOn)[dep].Condition == "service_healthy",
+				})
+			}
+		}
+		plan = append(plan, step)
+	}
+	return plan, nil
+}
+
+var (
+	regHostToken  = regexp.MustCompile(`[A-Za-z0-9_.-]+`)
+	regPortSuffix = regexp.MustCompile(`^:([0-9]+)`)
+)
+
+// SuggestDependsOn proposes depends_on edges for services whose environment
+// values mention another service as a host name, e.g. DB_HOST=postgres or
+// postgres://user@postgres:5432/app. A host:port reference may also name the
+// service by its hostname, container_name or a network alias, and only counts
+// when the port is one of the target ports of the service, if it lists any.
+// Existing edges are not repeated.
+func (conf *ComposeConfig) SuggestDependsOn() map[string][]string {
+	suggestions := map[string][]string{}
+	if conf.Services == nil {
+		return suggestions
+	}
+	graph := conf.dependencies()
+	hosts := map[string]string{}
+	targets := map[string]map[int]bool{}
+	for _, name := range conf.ServiceNames() {
+		serviceConf := (*conf.Services)[name]
+		for _, network := range serviceConf.Networks {
+			for _, alias := range network.Aliases {
+				hosts[alias] = name
+			}
+		}
+		for _, host := range []string{serviceConf.Hostname, serviceConf.ContainerName} {
+			if host != "" {
+				hosts[host] = name
+			}
+		}
+		// entries that fail to parse are reported by Validate
+		bindings, _ := serviceConf.ParsedPorts()
+		if len(bindings) > 0 {
+			targets[name] = map[int]bool{}
+			for _, binding := range bindings {
+				targets[name][binding.Target] = true
+			}
+		}
+	}
+	for _, name := range conf.ServiceNames() {
+		serviceConf := (*conf.Services)[name]
+		if serviceConf.Environment == nil {
+			continue
+		}
+		found := map[string]bool{}
+		for _, value := range serviceConf.Environment.Values {
+			for _, loc := range regHostToken.FindAllStringIndex(value, -1) {
+				rest := value[loc[1]:]
+				if strings.HasPrefix(rest, "://") {
+					continue
+				}
+				token := value[loc[0]:loc[1]]
+				dep := token
+				if match := regPortSuffix.FindStringSubmatch(rest); match != nil {
+					if _, ok := (*conf.Services)[dep]; !ok {
+						dep = hosts[token]
+					}
+					port, _ := strconv.Atoi(match[1])
+					if targets[dep] != nil && !targets[dep][port] {
+						continue
+					}
+				}
+				if dep == name {
+					continue
+				}
+				if _, ok := (*conf.Services)[dep]; !ok {
+					continue
+				}
+				if serviceConf.DependsOn != nil {
+					if _, ok := (*serviceConf.DependsOn)[dep]; ok {
+						continue
+					}
+				}
+				// an edge back to a service that already needs this one would
+				// only create a cycle
+				if reachable(graph, dep, name) {
+					continue
+				}
+				found[dep] = true
+			}
+		}
+		if len(found) > 0 {
+			suggestions[name] = sortedKeys(found)
+		}
+	}
+	return suggestions
+}
+
+// reachable reports whether to can be reached from from by following
+// dependency edges.
+func reachable(graph map[string][]string, from, to string) bool {
+	visited := map[string]bool{}
+	stack := []string{from}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if current == to {
+			return true
+		}
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+		stack = append(stack, graph[current]...)
+	}
+	return false
+}
+
+// dependents inverts the dependency graph: each service maps to the sorted
+// services that depend on it.
+func (conf *ComposeConfig) dependents() map[string][]string {
+	reverse := map[string][]string{}
+	graph := conf.dependencies()
+	for _, name := range sortedKeys(graph) {
+		for _, dep := range graph[name] {
+			reverse[dep] = append(reverse[dep], name)
+		}
+	}
+	return reverse
+}
+
+// Dependents returns the services that directly depend on serviceName.
+func (conf *ComposeConfig) Dependents(serviceName string) []string {
+	return append([]string{}, conf.dependents()[serviceName]...)
+}
+
+// TransitiveDependents returns every service that depends on serviceName,
+// directly or through other services, in sorted order.
+func (conf *ComposeConfig) TransitiveDependents(serviceName string) []string {
+	reverse := conf.dependents()
+	visited := map[string]bool{}
+	stack := append([]string{}, reverse[serviceName]...)
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if visited[current] || current == serviceName {
+			continue
+		}
+		visited[current] = true
+		stack = append(stack, reverse[current]...)
+	}
+	return sortedKeys(visited)
+}
+
+// ImpactOfImageChange returns, for every service whose image is of the given
+// repository, its transitive dependents: the services to redeploy when the
+// repository gets a new image. Images are compared by the Name of their
+// ParseImageReference, so a registry port is part of the repository while a
+// tag or digest is not; images that do not parse match nothing. Docker Hub
+// names are compared in full, so nginx, library/nginx and
+// docker.io/library/nginx are the same repository.
+func (conf *ComposeConfig) ImpactOfImageChange(repository string) map[string][]string {
+	impact := map[string][]string{}
+	want, err := ParseImageReference(repository)
+	if err != nil {
+		return impact
+	}
+	for _, name := range conf.ServiceNames() {
+		ref, err := ParseImageReference((*conf.Services)[name].Image)
+		if err == nil && ref.canonicalName() == want.canonicalName() {
+			impact[name] = conf.TransitiveDependents(name)
+		}
+	}
+	return impact
+}
+
+// ToDOT renders the depends_on graph as a Graphviz digraph, with an edge from
+// every service to each of its dependencies labeled with the condition.
+// Services with a healthcheck are drawn as double circles, and dependencies
+// that are not defined as dashed nodes.
+func (conf *ComposeConfig) ToDOT() []byte {
+	var b bytes.Buffer
+	b.WriteString("digraph compose {\n")
+	b.WriteString("\trankdir=LR;\n")
+	b.WriteString("\tnode [shape=box];\n")
+	undefined := map[string]bool{}
+	for _, name := range conf.ServiceNames() {
+		serviceConf := (*conf.Services)[name]
+		if serviceConf.Healthcheck != nil && !serviceConf.Healthcheck.IsDisabled() {
+			fmt.Fprintf(&b, "\t%s [shape=doublecircle];\n", dotQuote(name))
+		} else {
+			fmt.Fprintf(&b, "\t%s;\n", dotQuote(name))
+		}
+		if serviceConf.DependsOn != nil {
+			for _, dep := range sortedKeys(*serviceConf.DependsOn) {
+				if _, ok := (*conf.Services)[dep]; !ok {
+					undefined[dep] = true
+				}
+			}
+		}
+	}
+	for _, name := range sortedKeys(undefined) {
+		fmt.Fprintf(&b, "\t%s [style=dashed];\n", dotQuote(name))
+	}
+	for _, name := range conf.ServiceNames() {
+		dependsOn := (*conf.Services)[name].DependsOn
+		if dependsOn == nil {
+			continue
+		}
+		for _, dep := range sortedKeys(*dependsOn) {
+			condition := "service_started"
+			if dependent := (*dependsOn)[dep]; dependent != nil && dependent.Condition != "" {
+				condition = dependent.Condition
+			}
+			fmt.Fprintf(&b, "\t%s -> %s [label=%s];\n", dotQuote(name), dotQuote(dep), dotQuote(condition))
+		}
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestImpactOfImageChangeComparesRepositories(t *testing.T) {
+	conf := mustParse(t, `services:
+  api:
+    image: registry:5000/app:1.0
+  worker:
+    image: registry:5000/app@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef
+  web:
+    image: nginx
+    depends_on: [api]
+  other:
+    image: registry:5000/app-admin:1.0
+`)
+	impact := conf.ImpactOfImageChange("registry:5000/app")
+	want := map[string][]string{"api": {"web"}, "worker": {}}
+	if !reflect.DeepEqual(impact, want) {
+		t.Errorf("got %v, want %v", impact, want)
+	}
+	if impact := conf.ImpactOfImageChange("registry:5000/app:2.0"); !reflect.DeepEqual(impact, want) {
+		t.Errorf("with a tag got %v, want %v", impact, want)
+	}
+	if impact := conf.ImpactOfImageChange("app"); len(impact) != 0 {
+		t.Errorf("another registry got %v", impact)
+	}
+	want = map[string][]string{"web": {}}
+	for _, repository := range []string{"nginx", "library/nginx", "docker.io/library/nginx", "index.docker.io/nginx:1.27"} {
+		if impact := conf.ImpactOfImageChange(repository); !reflect.DeepEqual(impact, want) {
+			t.Errorf("%s got %v, want %v", repository, impact, want)
+		}
+	}
+}
+
+func TestSuggestDependsOnHostPortReferences(t *testing.T) {
+	conf := mustParse(t, `services:
+  app:
+    image: app
+    environment:
+      DB_URL: postgres://user@database:5432/app
+      CACHE: redis-host:6379
+      SEARCH: search:9300
+      QUEUE: queue:5672
+      STATUS: ok:200
+  db:
+    image: postgres
+    ports: ["5432"]
+    networks:
+      default:
+        aliases: [database]
+  cache:
+    image: redis
+    hostname: redis-host
+  search:
+    image: elasticsearch
+    ports: ["9200"]
+  queue:
+    image: rabbitmq
+`)
+	want := map[string][]string{"app": {"cache", "db", "queue"}}
+	if suggestions := conf.SuggestDependsOn(); !reflect.DeepEqual(suggestions, want) {
+		t.Errorf("got %v, want %v", suggestions, want)
+	}
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type HealthcheckOption func(*ComposeHealthcheckConfig, *healthcheckOptions)
+
+type healthcheckOptions struct {
+	wgetFallback bool
+}
+
+// WithWgetFallback makes an HTTP healthcheck fall back to wget for images
+// that do not ship curl.
+func WithWgetFallback() HealthcheckOption {
+	return func(_ *ComposeHealthcheckConfig, opts *healthcheckOptions) {
+		opts.wgetFallback = true
+	}
+}
+
+func WithStartPeriod(d time.Duration) HealthcheckOption {
+	return func(healthcheck *ComposeHealthcheckConfig, _ *healthcheckOptions) {
+		healthcheck.StartPeriod = formatDuration(d)
+	}
+}
+
+// NewHTTPHealthcheck probes url with curl -fsS, failing on HTTP errors.
+func NewHTTPHealthcheck(url string, interval, timeout time.Duration, retries uint64, opts ...HealthcheckOption) *ComposeHealthcheckConfig {
+	healthcheck, options := newHealthcheck(interval, timeout, retries, opts)
+	command := fmt.Sprintf("curl -fsS %s > /dev/null", shellQuote(url))
+	if options.wgetFallback {
+		command = fmt.Sprintf("(command -v curl > /dev/null && %s) || wget -q -O /dev/null %s", command, shellQuote(url))
+	}
+	healthcheck.Test = ComposeHealthCheckTest{"CMD-SHELL", command + " || exit 1"}
+	return healthcheck
+}
+
+// NewTCPHealthcheck checks that something accepts connections on port inside
+// the container.
+func NewTCPHealthcheck(port int, interval, timeout time.Duration, retries uint64, opts ...HealthcheckOption) *ComposeHealthcheckConfig {
+	healthcheck, _ := newHealthcheck(interval, timeout, retries, opts)
+	healthcheck.Test = ComposeHealthCheckTest{"CMD-SHELL", fmt.Sprintf("nc -z 127.0.0.1 %d || exit 1", port)}
+	return healthcheck
+}
+
+// NewCommandHealthcheck runs cmd directly, without a shell.
+func NewCommandHealthcheck(cmd ...string) *ComposeHealthcheckConfig {
+	return &ComposeHealthcheckConfig{
+		Test: append(ComposeHealthCheckTest{"CMD"}, cmd...),
+	}
+}
+
+func newHealthcheck(interval, timeout time.Duration, retries uint64, opts []HealthcheckOption) (*ComposeHealthcheckConfig, *healthcheckOptions) {
+	healthcheck := &ComposeHealthcheckConfig{
+		Interval: formatDuration(interval),
+		Timeout:  formatDuration(timeout),
+	}
+	if retries > 0 {
+		healthcheck.Retries = &retries
+	}
+	options := &healthcheckOptions{}
+	for _, opt := range opts {
+		opt(healthcheck, options)
+	}
+	return healthcheck, options
+}
+
+// IsDisabled reports whether the healthcheck turns off the one of the image,
+// through disable: true or a NONE test.
+func (healthcheck *ComposeHealthcheckConfig) IsDisabled() bool {
+	return healthcheck.Disable || len(healthcheck.Test) > 0 && healthcheck.Test[0] == "NONE"
+}
+
+// formatDuration renders d in the compose duration format, dropping the zero
+// components time.Duration.String keeps ("1h0m0s" becomes "1h"). Zero is
+// rendered as "" so the field is omitted.
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
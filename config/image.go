@@ -0,0 +1,206 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	regImageDomain        = regexp.MustCompile(`^(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*|\[[0-9a-fA-F:.]+\])(?::[0-9]+)?$`)
+	regImagePathComponent = regexp.MustCompile(`^[a-z0-9]+(?:(?:[_.]|__|-+)[a-z0-9]+)*$`)
+	regImageTag           = regexp.MustCompile(`^\w[\w.-]*$`)
+	regDigestAlgorithm    = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9]*(?:[-_+.][a-zA-Z][a-zA-Z0-9]*)*$`)
+	regDigestHex          = regexp.MustCompile(`^[0-9a-fA-F]{32,}$`)
+	regSHA256Hex          = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+const (
+	maxImageNameLength = 255
+	maxImageTagLength  = 128
+)
+
+// ImageRef is an image reference split along the distribution grammar:
+// [domain/]path[:tag][@digest].
+type ImageRef struct {
+	Domain string
+	Path   string
+	Tag    string
+	Digest string
+}
+
+// Name returns the repository part of the reference, without tag or digest.
+func (ref *ImageRef) Name() string {
+	if ref.Domain == "" {
+		return ref.Path
+	}
+	return ref.Domain + "/" + ref.Path
+}
+
+// canonicalName returns Name with the defaults docker fills in: images
+// without a registry come from docker.io, also known as index.docker.io, and
+// the official ones among them live under library/.
+func (ref *ImageRef) canonicalName() string {
+	domain := ref.Domain
+	if domain == "" || domain == "index.docker.io" {
+		domain = "docker.io"
+	}
+	path := ref.Path
+	if domain == "docker.io" && !strings.Contains(path, "/") {
+		path = "library/" + path
+	}
+	return domain + "/" + path
+}
+
+func (ref *ImageRef) String() string {
+	s := ref.Name()
+	if ref.Tag != "" {
+		s += ":" + ref.Tag
+	}
+	if ref.Digest != "" {
+		s += "@" + ref.Digest
+	}
+	return s
+}
+
+// ParseImageReference parses s strictly against the distribution reference
+// grammar, so unlike GetImageName and GetVersion it rejects empty tags,
+// uppercase repositories and malformed digests.
+func ParseImageReference(s string) (*ImageRef, error) {
+	if s == "" {
+		return nil, fmt.Errorf("invalid image reference: empty")
+	}
+	ref := &ImageRef{}
+	name := s
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, ref.Digest = name[:i], name[i+1:]
+		if err := validateDigest(ref.Digest); err != nil {
+			return nil, fmt.Errorf("invalid image reference %q: %w", s, err)
+		}
+	}
+	// a colon after the last slash starts the tag, earlier ones belong to a
+	// registry port
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref.Tag = name[:i], name[i+1:]
+		switch {
+		case ref.Tag == "":
+			return nil, fmt.Errorf("invalid image reference %q: empty tag", s)
+		case len(ref.Tag) > maxImageTagLength:
+			return nil, fmt.Errorf("invalid image reference %q: tag exceeds %d characters", s, maxImageTagLength)
+		case !regImageTag.MatchString(ref.Tag):
+			return nil, fmt.Errorf("invalid image reference %q: invalid tag %q", s, ref.Tag)
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("invalid image reference %q: empty repository", s)
+	}
+	if len(name) > maxImageNameLength {
+		return nil, fmt.Errorf("invalid image reference %q: repository name exceeds %d characters", s, maxImageNameLength)
+	}
+
+	components := strings.Split(name, "/")
+	if first := components[0]; len(components) > 1 && (strings.ContainsAny(first, ".:[") || first == "localhost") {
+		if !regImageDomain.MatchString(first) {
+			return nil, fmt.Errorf("invalid image reference %q: invalid registry %q", s, first)
+		}
+		ref.Domain, components = first, components[1:]
+	}
+	for _, component := range components {
+		switch {
+		case component == "":
+			return nil, fmt.Errorf("invalid image reference %q: empty path component", s)
+		case strings.ToLower(component) != component:
+			return nil, fmt.Errorf("invalid image reference %q: repository name must be lowercase", s)
+		case !regImagePathComponent.MatchString(component):
+			return nil, fmt.Errorf("invalid image reference %q: invalid characters in %q", s, component)
+		}
+	}
+	ref.Path = strings.Join(components, "/")
+	return ref, nil
+}
+
+func validateDigest(digest string) error {
+	algorithm, hex, ok := strings.Cut(digest, ":")
+	if !ok || !regDigestAlgorithm.MatchString(algorithm) || !regDigestHex.MatchString(hex) {
+		return fmt.Errorf("malformed digest %q", digest)
+	}
+	if algorithm == "sha256" && !regSHA256Hex.MatchString(hex) {
+		return fmt.Errorf("malformed digest %q: sha256 requires 64 lowercase hex characters", digest)
+	}
+	return nil
+}
+
+func ValidateImageRef(ref string) error {
+	_, err := ParseImageReference(ref)
+	return err
+}
+
+// ValidateImageReferences checks the image of every service with
+// ParseImageReference. Services without an image are only accepted when they
+// have a build section.
+func (conf *ComposeConfig) ValidateImageReferences() []error {
+	errs := []error{}
+	for _, name := range conf.ServiceNames() {
+		serviceConf := (*conf.Services)[name]
+		if serviceConf.Image == "" {
+			if serviceConf.Build == nil {
+				errs = append(errs, fmt.Errorf("service %s: no image", name))
+			}
+			continue
+		}
+		if err := ValidateImageRef(serviceConf.Image); err != nil {
+			errs = append(errs, fmt.Errorf("service %s: %w", name, err))
+		}
+	}
+	return errs
+}
+
+// PinToDigests rewrites the image of every service to repository@digest, with
+// the digest resolve returns for the current reference; the registry is kept
+// and the tag dropped. Services already pinned to a digest and services
+// without an image are skipped, and every reference is resolved once. Images
+// are only rewritten when all of them resolve.
+func (conf *ComposeConfig) PinToDigests(resolve func(ref ImageRef) (string, error)) error {
+	errs := &MultiError{}
+	resolved := map[string]string{}
+	pinned := map[string]string{}
+	for _, name := range conf.ServiceNames() {
+		image := (*conf.Services)[name].Image
+		if image == "" {
+			continue
+		}
+		if pin, ok := resolved[image]; ok {
+			if pin != "" {
+				pinned[name] = pin
+			}
+			continue
+		}
+		resolved[image] = ""
+		ref, err := ParseImageReference(image)
+		if err != nil {
+			errs.Append(fmt.Errorf("service %s: %w", name, err))
+			continue
+		}
+		if ref.Digest != "" {
+			continue
+		}
+		digest, err := resolve(*ref)
+		if err != nil {
+			errs.Append(fmt.Errorf("service %s: resolving %s: %w", name, image, err))
+			continue
+		}
+		if err = validateDigest(digest); err != nil {
+			errs.Append(fmt.Errorf("service %s: resolving %s: %w", name, image, err))
+			continue
+		}
+		resolved[image] = ref.Name() + "@" + digest
+		pinned[name] = resolved[image]
+	}
+	if err := errs.ErrorOrNil(); err != nil {
+		return err
+	}
+	for name, image := range pinned {
+		(*conf.Services)[name].Image = image
+	}
+	return nil
+}
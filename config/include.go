@@ -0,0 +1,228 @@
+package config
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type ComposeIncludeConfig struct {
+	// Path lists the file to include followed by optional override files.
+	Path             []string `json:"path" yaml:"path"`
+	ProjectDirectory string   `json:"project_directory,omitempty" yaml:"project_directory,omitempty"`
+	EnvFile          []string `json:"env_file,omitempty" yaml:"env_file,omitempty"`
+}
+
+func (include *ComposeIncludeConfig) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		include.Path = []string{node.Value}
+		return nil
+	case yaml.MappingNode:
+		var raw struct {
+			Path             yaml.Node `yaml:"path"`
+			ProjectDirectory string    `yaml:"project_directory"`
+			EnvFile          yaml.Node `yaml:"env_file"`
+		}
+		if err := node.Decode(&raw); err != nil {
+			return err
+		}
+		var err error
+		if include.Path, err = decodeStringOrList(&raw.Path, "include.path"); err != nil {
+			return err
+		}
+		if len(include.Path) == 0 {
+			return newParseError(node, "include", "missing path")
+		}
+		if include.EnvFile, err = decodeStringOrList(&raw.EnvFile, "include.env_file"); err != nil {
+			return err
+		}
+		include.ProjectDirectory = raw.ProjectDirectory
+		return nil
+	}
+	return newParseError(node, "include", "invalid include format")
+}
+
+func (include *ComposeIncludeConfig) MarshalYAML() (any, error) {
+	if len(include.Path) == 1 && include.ProjectDirectory == "" && len(include.EnvFile) == 0 {
+		return include.Path[0], nil
+	}
+	type plain ComposeIncludeConfig
+	return (*plain)(include), nil
+}
+
+func decodeStringOrList(node *yaml.Node, field string) ([]string, error) {
+	switch node.Kind {
+	case 0:
+		return nil, nil
+	case yaml.ScalarNode:
+		return []string{node.Value}, nil
+	case yaml.SequenceNode:
+		var list []string
+		if err := node.Decode(&list); err != nil {
+			return nil, newParseError(node, field, "expected a list of strings")
+		}
+		return list, nil
+	}
+	return nil, newParseError(node, field, "expected a string or a list of strings")
+}
+
+type ComposeIncludesConfig []*ComposeIncludeConfig
+
+// ResolveIncludes loads every file listed under include, relative to baseDir,
+// and adds its services, networks, volumes, secrets and configs to conf.
+// Relative paths of an included file are resolved against its project
+// directory, and like compose it is an error for an included file to define a
+// resource that already exists. When an include sets env_file the included
+// file is interpolated with those variables, falling back to the process
+// environment.
+func (conf *ComposeConfig) ResolveIncludes(baseDir string) error {
+	return conf.resolveIncludes(baseDir, nil)
+}
+
+func (conf *ComposeConfig) resolveIncludes(baseDir string, chain []string) error {
+	includes := conf.Include
+	conf.Include = nil
+	for _, include := range includes {
+		included, err := loadInclude(baseDir, include, chain)
+		if err != nil {
+			return err
+		}
+		if err = conf.addIncluded(included, include.Path[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadInclude(baseDir string, include *ComposeIncludeConfig, chain []string) (*ComposeConfig, error) {
+	var included *ComposeConfig
+	var mainPath string
+	for i, path := range include.Path {
+		path = absHostPath(baseDir, path)
+		if i == 0 {
+			mainPath = path
+			for _, parent := range chain {
+				if parent == path {
+					return nil, fmt.Errorf("include cycle: %s", strings.Join(append(chain, path), " -> "))
+				}
+			}
+		}
+		conf, err := GetConfigFromComposeFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if included == nil {
+			included = conf
+		} else {
+			included.Merge(conf)
+		}
+	}
+
+	projectDir := filepath.Dir(mainPath)
+	if include.ProjectDirectory != "" {
+		projectDir = absHostPath(baseDir, include.ProjectDirectory)
+	}
+	if len(include.EnvFile) > 0 {
+		env := map[string]string{}
+		for _, envFile := range include.EnvFile {
+			fileEnv, err := ReadEnvFile(absHostPath(baseDir, envFile))
+			if err != nil {
+				return nil, err
+			}
+			for key, value := range fileEnv {
+				env[key] = value
+			}
+		}
+		err := included.Interpolate(func(name string) (string, bool) {
+			if value, ok := env[name]; ok {
+				return value, true
+			}
+			return os.LookupEnv(name)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", mainPath, err)
+		}
+	}
+	chain = append(append([]string{}, chain...), mainPath)
+	if err := included.resolveIncludes(filepath.Dir(mainPath), chain); err != nil {
+		return nil, err
+	}
+	included.rebaseHostPaths(projectDir)
+	return included, nil
+}
+
+// rebaseHostPaths makes the relative host paths of conf absolute against dir,
+// so they keep pointing at the same files once merged into another project.
+func (conf *ComposeConfig) rebaseHostPaths(dir string) {
+	for _, name := range conf.ServiceNames() {
+		serviceConf := (*conf.Services)[name]
+		for i, volume := range serviceConf.Volumes {
+			if mount, err := volume.Mount(); err == nil && mount.Type == VolumeTypeBind {
+				mount.Source = absHostPath(dir, mount.Source)
+				serviceConf.Volumes[i].SetMount(mount)
+			}
+		}
+		for i, envFile := range serviceConf.EnvFile {
+			serviceConf.EnvFile[i].Path = absHostPath(dir, envFile.Path)
+		}
+		if serviceConf.Build != nil && !isRemoteContext(serviceConf.Build.Context) {
+			serviceConf.Build.Context = absHostPath(dir, serviceConf.Build.Context)
+		}
+	}
+	for _, secretConf := range conf.Secrets {
+		if secretConf != nil && secretConf.File != "" {
+			secretConf.File = absHostPath(dir, secretConf.File)
+		}
+	}
+	for _, configConf := range conf.Configs {
+		if configConf != nil && configConf.File != "" {
+			configConf.File = absHostPath(dir, configConf.File)
+		}
+	}
+}
+
+func (conf *ComposeConfig) addIncluded(included *ComposeConfig, source string) error {
+	if included.Services != nil {
+		if conf.Services == nil {
+			conf.Services = &ComposeServicesConfig{}
+		}
+		if err := addIncludedResources(*conf.Services, *included.Services, "service", source); err != nil {
+			return err
+		}
+	}
+	if conf.Networks == nil && included.Networks != nil {
+		conf.Networks = map[string]*ComposeNetworkConfig{}
+	}
+	if err := addIncludedResources(conf.Networks, included.Networks, "network", source); err != nil {
+		return err
+	}
+	if conf.Volumes == nil && included.Volumes != nil {
+		conf.Volumes = map[string]*ComposeVolumeConfig{}
+	}
+	if err := addIncludedResources(conf.Volumes, included.Volumes, "volume", source); err != nil {
+		return err
+	}
+	if conf.Secrets == nil && included.Secrets != nil {
+		conf.Secrets = map[string]*ComposeSecretConfig{}
+	}
+	if err := addIncludedResources(conf.Secrets, included.Secrets, "secret", source); err != nil {
+		return err
+	}
+	if conf.Configs == nil && included.Configs != nil {
+		conf.Configs = map[string]*ComposeConfigObjConfig{}
+	}
+	return addIncludedResources(conf.Configs, included.Configs, "config", source)
+}
+
+func addIncludedResources[V any](dst, src map[string]V, kind, source string) error {
+	for _, name := range sortedKeys(src) {
+		if _, exists := dst[name]; exists {
+			return fmt.Errorf("%s %s from included file %s conflicts with an existing definition", kind, name, source)
+		}
+		dst[name] = src[name]
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"sort"
+	"strings"
+)
+
+// ServiceDiff lists how a running container drifted from its service. Old
+// holds the value of the config and New the one of the container.
+type ServiceDiff struct {
+	Service string
+	Changes []FieldChange
+}
+
+func (d *ServiceDiff) Empty() bool {
+	return len(d.Changes) == 0
+}
+
+func (d *ServiceDiff) String() string {
+	lines := make([]string, 0, len(d.Changes))
+	for _, change := range d.Changes {
+		lines = append(lines, change.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// containerInspect is the part of the docker inspect output DiffAgainstInspect
+// compares.
+type containerInspect struct {
+	Config struct {
+		Image string   `json:"Image"`
+		Env   []string `json:"Env"`
+	} `json:"Config"`
+	HostConfig struct {
+		RestartPolicy struct {
+			Name              string `json:"Name"`
+			MaximumRetryCount int    `json:"MaximumRetryCount"`
+		} `json:"RestartPolicy"`
+		PortBindings map[string][]struct {
+			HostIP   string `json:"HostIp"`
+			HostPort string `json:"HostPort"`
+		} `json:"PortBindings"`
+	} `json:"HostConfig"`
+}
+
+// DiffAgainstInspect compares the service with the docker inspect output of
+// one of its containers, either the array docker inspect prints or a single
+// element of it. It reports differences in image, environment, published
+// ports and restart policy. Only the variables the service sets are
+// compared, since the container also carries the ones of its image.
+func (conf *ComposeConfig) DiffAgainstInspect(serviceName string, inspect []byte) (*ServiceDiff, error) {
+	serviceConf, err := conf.LookupService(serviceName)
+	if err != nil {
+		return nil, err
+	}
+	container, err := parseContainerInspect(inspect)
+	if err != nil {
+		return nil, err
+	}
+	diff := &ServiceDiff{Service: serviceName, Changes: []FieldChange{}}
+	changed := func(path string, old, new any) {
+		diff.Changes = append(diff.Changes, FieldChange{Path: path, Kind: ChangeChanged, Old: old, New: new})
+	}
+
+	if serviceConf.Image != "" && comparableImage(serviceConf.Image) != comparableImage(container.Config.Image) {
+		changed("image", serviceConf.Image, container.Config.Image)
+	}
+
+	if serviceConf.Environment != nil {
+		running := map[string]string{}
+		for _, entry := range container.Config.Env {
+			key, value, _ := strings.Cut(entry, "=")
+			running[key] = value
+		}
+		for _, key := range serviceConf.Environment.Keys() {
+			want, _ := serviceConf.Environment.Get(key)
+			got, ok := running[key]
+			switch {
+			case !ok:
+				diff.Changes = append(diff.Changes, FieldChange{Path: joinPath("environment", key), Kind: ChangeRemoved, Old: want})
+			case got != want:
+				changed(joinPath("environment", key), want, got)
+			}
+		}
+	}
+
+	bindings, err := serviceConf.ParsedPorts()
+	if err != nil {
+		return nil, fmt.Errorf("service %s: %w", serviceName, err)
+	}
+	wantPorts := map[string]bool{}
+	for _, binding := range bindings {
+		wantPorts[comparablePort(binding)] = true
+	}
+	gotPorts := map[string]bool{}
+	for containerPort, hostBindings := range container.HostConfig.PortBindings {
+		port, protocol, _ := strings.Cut(containerPort, "/")
+		target, err := parsePort(port)
+		if err != nil {
+			return nil, fmt.Errorf("invalid container port %q in inspect output", containerPort)
+		}
+		for _, hostBinding := range hostBindings {
+			binding := PortBinding{HostIP: hostBinding.HostIP, Target: target, Protocol: protocol}
+			// a host port range is kept as it was published
+			if hostBinding.HostPort != "" {
+				start, end, err := parsePortRange(hostBinding.HostPort)
+				if err != nil {
+					return nil, fmt.Errorf("invalid host port %q in inspect output", hostBinding.HostPort)
+				}
+				binding.Published = start
+				if start != end {
+					binding.PublishedEnd = end
+				}
+			}
+			gotPorts[comparablePort(binding)] = true
+		}
+	}
+	for _, port := range sortedKeys(wantPorts) {
+		if !gotPorts[port] {
+			diff.Changes = append(diff.Changes, FieldChange{Path: "ports", Kind: ChangeRemoved, Old: port})
+		}
+	}
+	for _, port := range sortedKeys(gotPorts) {
+		if !wantPorts[port] {
+			diff.Changes = append(diff.Changes, FieldChange{Path: "ports", Kind: ChangeAdded, New: port})
+		}
+	}
+
+	wantRestart, err := serviceConf.dockerRestart()
+	if err != nil {
+		return nil, fmt.Errorf("service %s: %w", serviceName, err)
+	}
+	restartPolicy := container.HostConfig.RestartPolicy
+	gotRestart := restartPolicy.Name
+	if gotRestart == "" {
+		gotRestart = "no"
+	}
+	if gotRestart == "on-failure" && restartPolicy.MaximumRetryCount > 0 {
+		gotRestart = fmt.Sprintf("on-failure:%d", restartPolicy.MaximumRetryCount)
+	}
+	if gotRestart != wantRestart {
+		changed("restart", wantRestart, gotRestart)
+	}
+
+	sort.SliceStable(diff.Changes, func(i, j int) bool {
+		return diff.Changes[i].Path < diff.Changes[j].Path
+	})
+	return diff, nil
+}
+
+func parseContainerInspect(inspect []byte) (*containerInspect, error) {
+	trimmed := bytes.TrimSpace(inspect)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var containers []*containerInspect
+		if err := jsoniter.Unmarshal(trimmed, &containers); err != nil {
+			return nil, fmt.Errorf("invalid inspect output: %w", err)
+		}
+		if len(containers) != 1 {
+			return nil, fmt.Errorf("inspect output holds %d containers, expected 1", len(containers))
+		}
+		return containers[0], nil
+	}
+	container := &containerInspect{}
+	if err := jsoniter.Unmarshal(trimmed, container); err != nil {
+		return nil, fmt.Errorf("invalid inspect output: %w", err)
+	}
+	return container, nil
+}
+
+// comparableImage spells out the defaults docker fills into a reference, so
+// that nginx and docker.io/library/nginx:latest compare equal.
+func comparableImage(image string) string {
+	ref, err := ParseImageReference(image)
+	if err != nil {
+		return image
+	}
+	if ref.Domain == "" || ref.Domain == "index.docker.io" {
+		ref.Domain = "docker.io"
+	}
+	if ref.Domain == "docker.io" && !strings.Contains(ref.Path, "/") {
+		ref.Path = "library/" + ref.Path
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	return ref.String()
+}
+
+func comparablePort(binding PortBinding) string {
+	if binding.HostIP == "0.0.0.0" || binding.HostIP == "::" {
+		binding.HostIP = ""
+	}
+	if binding.Protocol == "" {
+		binding.Protocol = "tcp"
+	}
+	return FormatPortSpec([]PortBinding{binding})[0]
+}
+
+// dockerRestart returns the docker restart policy the service runs with, in
+// the form of the restart field.
+func (serviceConf *ComposeServiceConfig) dockerRestart() (string, error) {
+	if serviceConf.Restart != "" || serviceConf.Deploy == nil || serviceConf.Deploy.RestartPolicy == nil {
+		if _, err := parseRestart(serviceConf.Restart); err != nil {
+			return "", err
+		}
+		if serviceConf.Restart == "" {
+			return "no", nil
+		}
+		return serviceConf.Restart, nil
+	}
+	policy, err := serviceConf.EffectiveRestartPolicy()
+	if err != nil {
+		return "", err
+	}
+	switch policy.Condition {
+	case RestartConditionNone:
+		return "no", nil
+	case RestartConditionOnFailure:
+		if policy.MaxAttempts != nil {
+			return fmt.Sprintf("on-failure:%d", *policy.MaxAttempts), nil
+		}
+		return "on-failure", nil
+	default:
+		return "always", nil
+	}
+}
@@ -0,0 +1,281 @@
+package config
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"strings"
+)
+
+// InterpolateString substitutes $VAR and ${VAR} references in s following the
+// compose rules, including the :-, -, :?, ?, :+ and + modifiers. "$$" escapes a
+// literal dollar sign and unset variables without a default become empty.
+func InterpolateString(s string, lookup func(string) (string, bool)) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		next := s[i+1]
+		switch {
+		case next == '$':
+			b.WriteByte('$')
+			i++
+		case next == '{':
+			end := matchingBrace(s, i+1)
+			if end < 0 {
+				return "", fmt.Errorf("invalid interpolation format: %s", s)
+			}
+			value, err := substituteBraced(s[i+2:end], lookup)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			i = end
+		case isVarNameStart(next):
+			j := i + 1
+			for j < len(s) && isVarNameChar(s[j]) {
+				j++
+			}
+			value, _ := lookup(s[i+1 : j])
+			b.WriteString(value)
+			i = j - 1
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), nil
+}
+
+func substituteBraced(expr string, lookup func(string) (string, bool)) (string, error) {
+	n := 0
+	for n < len(expr) && isVarNameChar(expr[n]) {
+		n++
+	}
+	name, rest := expr[:n], expr[n:]
+	if name == "" || !isVarNameStart(name[0]) {
+		return "", fmt.Errorf("invalid interpolation format: ${%s}", expr)
+	}
+	value, ok := lookup(name)
+	if rest == "" {
+		return value, nil
+	}
+
+	colon := strings.HasPrefix(rest, ":")
+	if colon {
+		rest = rest[1:]
+	}
+	if rest == "" {
+		return "", fmt.Errorf("invalid interpolation format: ${%s}", expr)
+	}
+	operator, argument := rest[0], rest[1:]
+	// with a colon an empty value counts as unset
+	set := ok && (!colon || value != "")
+	switch operator {
+	case '-':
+		if set {
+			return value, nil
+		}
+		return InterpolateString(argument, lookup)
+	case '?':
+		if set {
+			return value, nil
+		}
+		message, err := InterpolateString(argument, lookup)
+		if err != nil {
+			return "", err
+		}
+		if message == "" {
+			message = "required variable is missing a value"
+		}
+		return "", fmt.Errorf("%s: %s", name, message)
+	case '+':
+		if !set {
+			return "", nil
+		}
+		return InterpolateString(argument, lookup)
+	default:
+		return "", fmt.Errorf("invalid interpolation format: ${%s}", expr)
+	}
+}
+
+func matchingBrace(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isVarNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isVarNameChar(c byte) bool {
+	return isVarNameStart(c) || (c >= '0' && c <= '9')
+}
+
+// Interpolate substitutes variables in every string value of the config.
+func (conf *ComposeConfig) Interpolate(lookup func(string) (string, bool)) error {
+	return rewriteStrings(reflect.ValueOf(conf), func(s string) (string, error) {
+		return InterpolateString(s, lookup)
+	})
+}
+
+// rewriteStrings replaces every string reachable from v, including map values
+// but not map keys, with the result of fn.
+func rewriteStrings(v reflect.Value, fn func(string) (string, error)) error {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Interface {
+			elem := reflect.New(v.Elem().Type()).Elem()
+			elem.Set(v.Elem())
+			if err := rewriteStrings(elem, fn); err != nil {
+				return err
+			}
+			if v.CanSet() {
+				v.Set(elem)
+			}
+			return nil
+		}
+		return rewriteStrings(v.Elem(), fn)
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(yaml.Node{}) && v.CanAddr() {
+			return rewriteNodeStrings(v.Addr().Interface().(*yaml.Node), fn)
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			if err := rewriteStrings(v.Field(i), fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := rewriteStrings(v.Index(i), fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			elem := reflect.New(iter.Value().Type()).Elem()
+			elem.Set(iter.Value())
+			if err := rewriteStrings(elem, fn); err != nil {
+				return err
+			}
+			v.SetMapIndex(iter.Key(), elem)
+		}
+	case reflect.String:
+		if !v.CanSet() {
+			return nil
+		}
+		value, err := fn(v.String())
+		if err != nil {
+			return err
+		}
+		v.SetString(value)
+	}
+	return nil
+}
+
+// rewriteNodeStrings rewrites the scalar values of a raw YAML node, leaving
+// mapping keys, tags and comments alone.
+func rewriteNodeStrings(node *yaml.Node, fn func(string) (string, error)) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		value, err := fn(node.Value)
+		if err != nil {
+			return err
+		}
+		node.Value = value
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := rewriteNodeStrings(node.Content[i], fn); err != nil {
+				return err
+			}
+		}
+	default:
+		for _, child := range node.Content {
+			if err := rewriteNodeStrings(child, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// scanVars calls fn for every variable referenced by s. hasDefault is true when
+// the reference can never fail, i.e. uses the -, :-, + or :+ modifiers.
+func scanVars(s string, fn func(name string, hasDefault bool)) {
+	for i := 0; i < len(s)-1; i++ {
+		if s[i] != '$' {
+			continue
+		}
+		next := s[i+1]
+		switch {
+		case next == '$':
+			i++
+		case next == '{':
+			end := matchingBrace(s, i+1)
+			if end < 0 {
+				return
+			}
+			expr := s[i+2 : end]
+			n := 0
+			for n < len(expr) && isVarNameChar(expr[n]) {
+				n++
+			}
+			if n > 0 {
+				rest := strings.TrimPrefix(expr[n:], ":")
+				hasDefault := strings.HasPrefix(rest, "-") || strings.HasPrefix(rest, "+")
+				fn(expr[:n], hasDefault)
+				if rest != "" {
+					scanVars(rest[1:], fn)
+				}
+			}
+			i = end
+		case isVarNameStart(next):
+			j := i + 1
+			for j < len(s) && isVarNameChar(s[j]) {
+				j++
+			}
+			fn(s[i+1:j], false)
+			i = j - 1
+		}
+	}
+}
+
+// MissingInterpolationVars returns the sorted names of variables referenced
+// without a default that lookup cannot resolve.
+func (conf *ComposeConfig) MissingInterpolationVars(lookup func(string) (string, bool)) []string {
+	missing := map[string]bool{}
+	rewriteStrings(reflect.ValueOf(conf), func(s string) (string, error) {
+		scanVars(s, func(name string, hasDefault bool) {
+			if hasDefault {
+				return
+			}
+			if _, ok := lookup(name); !ok {
+				missing[name] = true
+			}
+		})
+		return s, nil
+	})
+	return sortedKeys(missing)
+}
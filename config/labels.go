@@ -0,0 +1,75 @@
+package config
+
+import (
+	"github.com/docker/cli/cli/compose/types"
+)
+
+const (
+	LabelGitRepository = "git.repository"
+	LabelGitBranch     = "git.branch"
+	LabelGitCommit     = "git.commit"
+)
+
+type GitMetadata struct {
+	Repository string
+	Branch     string
+	Commit     string
+}
+
+func (serviceConf *ComposeServiceConfig) GetLabel(key string) (string, bool) {
+	if serviceConf.Labels == nil {
+		return "", false
+	}
+	value, ok := (*serviceConf.Labels)[key]
+	return value, ok
+}
+
+func (serviceConf *ComposeServiceConfig) SetLabel(key, value string) {
+	if serviceConf.Labels == nil {
+		serviceConf.Labels = &types.Labels{}
+	}
+	(*serviceConf.Labels)[key] = value
+}
+
+// SetLabels sets every label of labels, leaving the ones the service already
+// has alone unless overwrite is set.
+func (serviceConf *ComposeServiceConfig) SetLabels(labels map[string]string, overwrite bool) {
+	for key, value := range labels {
+		if _, ok := serviceConf.GetLabel(key); overwrite || !ok {
+			serviceConf.SetLabel(key, value)
+		}
+	}
+}
+
+// LabelAllServices sets the label on every service, replacing any value it
+// had.
+func (conf *ComposeConfig) LabelAllServices(key, value string) {
+	for _, name := range conf.ServiceNames() {
+		(*conf.Services)[name].SetLabel(key, value)
+	}
+}
+
+func (serviceConf *ComposeServiceConfig) GitMetadata() GitMetadata {
+	repository, _ := serviceConf.GetLabel(LabelGitRepository)
+	branch, _ := serviceConf.GetLabel(LabelGitBranch)
+	commit, _ := serviceConf.GetLabel(LabelGitCommit)
+	return GitMetadata{
+		Repository: repository,
+		Branch:     branch,
+		Commit:     commit,
+	}
+}
+
+// SetGitMetadata stamps the git labels of the service; empty fields leave the
+// corresponding label untouched.
+func (serviceConf *ComposeServiceConfig) SetGitMetadata(metadata GitMetadata) {
+	for key, value := range map[string]string{
+		LabelGitRepository: metadata.Repository,
+		LabelGitBranch:     metadata.Branch,
+		LabelGitCommit:     metadata.Commit,
+	} {
+		if value != "" {
+			serviceConf.SetLabel(key, value)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LazyConfig reads a compose file without decoding its services up front:
+// each service is parsed on the first GetService call and cached, and
+// services never parsed are exported straight from the nodes they were read
+// from. Like ComposeConfig it is not safe for concurrent use.
+type LazyConfig struct {
+	root     *yaml.Node
+	services *yaml.Node
+	names    []string
+	raw      map[string]*yaml.Node
+	parsed   map[string]*ComposeServiceConfig
+}
+
+func GetLazyConfigFromComposeFile(composeFilePath string) (*LazyConfig, error) {
+	content, err := os.ReadFile(composeFilePath)
+	if err != nil {
+		return nil, err
+	}
+	lazy, err := GetLazyConfigFromBytes(content)
+	if err != nil {
+		return nil, withSource(err, composeFilePath)
+	}
+	return lazy, nil
+}
+
+// GetLazyConfigFromBytes only checks the document down to the services
+// mapping; errors inside a service surface when it is parsed.
+func GetLazyConfigFromBytes(data []byte) (*LazyConfig, error) {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(data, doc); err != nil {
+		return nil, err
+	}
+	root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	if len(doc.Content) > 0 {
+		root = doc.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil, newParseError(root, "", "invalid compose file format")
+	}
+	untagMergeKeys(root)
+	lazy := &LazyConfig{
+		root:   root,
+		raw:    map[string]*yaml.Node{},
+		parsed: map[string]*ComposeServiceConfig{},
+	}
+	if services := mappingValue(root, "services"); services != nil {
+		if services.Kind != yaml.MappingNode {
+			return nil, newParseError(services, "services", "invalid services format")
+		}
+		lazy.services = services
+		for i := 0; i+1 < len(services.Content); i += 2 {
+			name := services.Content[i].Value
+			lazy.names = append(lazy.names, name)
+			lazy.raw[name] = services.Content[i+1]
+		}
+	}
+	return lazy, nil
+}
+
+// ServiceNames returns the service names in file order.
+func (lazy *LazyConfig) ServiceNames() []string {
+	return append([]string{}, lazy.names...)
+}
+
+// GetService parses the service called name on first use. Changes made to the
+// returned service are part of later exports.
+func (lazy *LazyConfig) GetService(name string) (*ComposeServiceConfig, error) {
+	if serviceConf, ok := lazy.parsed[name]; ok {
+		return serviceConf, nil
+	}
+	node, ok := lazy.raw[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrServiceNotFound, name)
+	}
+	serviceConf, err := decodeService(name, node)
+	if err != nil {
+		return nil, err
+	}
+	lazy.parsed[name] = serviceConf
+	return serviceConf, nil
+}
+
+// Config decodes the whole file, using the already parsed services as they
+// are now.
+func (lazy *LazyConfig) Config() (*ComposeConfig, error) {
+	conf := &ComposeConfig{}
+	if err := lazy.root.Decode(conf); err != nil {
+		return nil, err
+	}
+	for name, serviceConf := range lazy.parsed {
+		if conf.Services == nil {
+			conf.Services = &ComposeServicesConfig{}
+		}
+		conf.SetService(name, serviceConf)
+	}
+	return conf, nil
+}
+
+// ExportYAML writes the file back with the parsed services re-encoded and
+// everything else as it was read, in file order. Aliases of anchors defined
+// inside a re-encoded service are expanded, since the anchor is gone.
+func (lazy *LazyConfig) ExportYAML() ([]byte, error) {
+	opts := YAMLOptions{}
+	replaced := map[*yaml.Node]bool{}
+	encoded := map[string]*yaml.Node{}
+	for name, serviceConf := range lazy.parsed {
+		node := &yaml.Node{}
+		if err := node.Encode(serviceConf); err != nil {
+			return nil, err
+		}
+		applyYAMLOptions(node, opts)
+		encoded[name] = node
+		collectAnchors(lazy.raw[name], replaced)
+	}
+
+	root := &yaml.Node{Kind: lazy.root.Kind, Tag: lazy.root.Tag, Style: lazy.root.Style}
+	for i := 0; i+1 < len(lazy.root.Content); i += 2 {
+		key, value := lazy.root.Content[i], lazy.root.Content[i+1]
+		if value == lazy.services {
+			services := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				name, serviceNode := value.Content[j], value.Content[j+1]
+				if node, ok := encoded[name.Value]; ok {
+					serviceNode = node
+				} else {
+					serviceNode = expandAliases(serviceNode, replaced)
+				}
+				services.Content = append(services.Content, name, serviceNode)
+			}
+			value = services
+		} else {
+			value = expandAliases(value, replaced)
+		}
+		root.Content = append(root.Content, key, value)
+	}
+	return encodeYAMLDocument(root, opts)
+}
+
+func collectAnchors(node *yaml.Node, anchors map[*yaml.Node]bool) {
+	if node.Anchor != "" {
+		anchors[node] = true
+	}
+	for _, child := range node.Content {
+		collectAnchors(child, anchors)
+	}
+}
+
+// expandAliases returns node with the aliases of targets replaced by copies
+// of what they refer to, copying only the parts that change.
+func expandAliases(node *yaml.Node, targets map[*yaml.Node]bool) *yaml.Node {
+	if len(targets) == 0 {
+		return node
+	}
+	if node.Kind == yaml.AliasNode && targets[node.Alias] {
+		expanded := *expandAliases(node.Alias, targets)
+		if expanded.Content != nil {
+			expanded.Content = append([]*yaml.Node{}, expanded.Content...)
+		}
+		expanded.Anchor = ""
+		return &expanded
+	}
+	var content []*yaml.Node
+	for i, child := range node.Content {
+		if expanded := expandAliases(child, targets); expanded != child {
+			if content == nil {
+				content = append([]*yaml.Node{}, node.Content...)
+			}
+			content[i] = expanded
+		}
+	}
+	if content == nil {
+		return node
+	}
+	copied := *node
+	copied.Content = content
+	return &copied
+}
+
+// SetServiceVersionYAML sets the tag of the image of serviceName in a YAML
+// compose file the way SetVersion does, changing nothing but that scalar:
+// key order, comments, blank lines and indentation stay as they are. The
+// image must be set in the service itself rather than through a merge key.
+func SetServiceVersionYAML(data []byte, serviceName, version string) ([]byte, error) {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(data, doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+	services := mappingValue(doc.Content[0], "services")
+	if services == nil {
+		return nil, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+	service := mappingValue(services, serviceName)
+	if service == nil {
+		return nil, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+	if service.Kind == yaml.AliasNode {
+		service = service.Alias
+	}
+	image := mappingValue(service, "image")
+	if image == nil || image.Kind != yaml.ScalarNode {
+		return nil, newParseError(service, "services."+serviceName, "service %s sets no image of its own", serviceName)
+	}
+	updated := &ComposeServiceConfig{Image: image.Value}
+	updated.SetVersion(version)
+
+	if content, ok := spliceScalar(data, image, updated.Image); ok {
+		return content, nil
+	}
+	// the scalar spans lines or holds escapes, re-encode the document
+	image.Value, image.Style = updated.Image, 0
+	if isAmbiguousScalar(updated.Image) {
+		image.Style = yaml.DoubleQuotedStyle
+	}
+	return encodeYAMLDocument(doc.Content[0], YAMLOptions{Indent: detectIndent(data)})
+}
+
+// spliceScalar replaces the source text of a single-line plain or quoted
+// scalar node, keeping its quoting. ok is false when the source at the
+// position of node is not simply its value.
+func spliceScalar(data []byte, node *yaml.Node, value string) ([]byte, bool) {
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	if node.Line < 1 || node.Line > len(lines) {
+		return nil, false
+	}
+	offset := 0
+	for _, line := range lines[:node.Line-1] {
+		offset += len(line)
+	}
+	line := string(lines[node.Line-1])
+	runes := []rune(line)
+	if node.Column < 1 || node.Column > len(runes) {
+		return nil, false
+	}
+	start := offset + len(string(runes[:node.Column-1]))
+	source := node.Value
+	replacement := value
+	switch node.Style {
+	case 0:
+		if isAmbiguousScalar(value) {
+			replacement = strconv.Quote(value)
+		}
+	case yaml.DoubleQuotedStyle:
+		source, replacement = `"`+node.Value+`"`, strconv.Quote(value)
+	case yaml.SingleQuotedStyle:
+		if strings.Contains(value, "'") {
+			return nil, false
+		}
+		source, replacement = "'"+node.Value+"'", "'"+value+"'"
+	default:
+		return nil, false
+	}
+	if strings.ContainsAny(node.Value, "\\\"'\n") || !bytes.HasPrefix(data[start:], []byte(source)) {
+		return nil, false
+	}
+	content := make([]byte, 0, len(data)+len(replacement)-len(source))
+	content = append(content, data[:start]...)
+	content = append(content, replacement...)
+	return append(content, data[start+len(source):]...), true
+}
+
+// detectIndent returns the smallest indentation of the mapping keys of a
+// YAML file, or 0 when nothing is indented.
+func detectIndent(data []byte) int {
+	indent := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "-") {
+			continue
+		}
+		if n := len(line) - len(trimmed); n > 0 && (indent == 0 || n < indent) {
+			indent = n
+		}
+	}
+	return indent
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSetServiceVersionYAMLOnlyChangesTheImage(t *testing.T) {
+	data := `# top comment
+services:
+  web:
+    # the web server
+    environment:
+      A: "1"
+
+    image: registry:5000/web:1.0   # pinned
+  db:
+    image: "postgres:15"
+`
+	content, err := SetServiceVersionYAML([]byte(data), "web", "2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(data, "web:1.0", "web:2.0", 1)
+	if string(content) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", content, want)
+	}
+
+	content, err = SetServiceVersionYAML([]byte(data), "db", "16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Replace(data, `"postgres:15"`, `"postgres:16"`, 1); string(content) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", content, want)
+	}
+
+	if _, err = SetServiceVersionYAML([]byte(data), "cache", "1"); err == nil {
+		t.Error("no error for a missing service")
+	}
+}
+
+// benchmarkCompose is a file with many services, of which the lazy config
+// only needs one.
+func benchmarkCompose() []byte {
+	b := &strings.Builder{}
+	b.WriteString("services:\n")
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(b, `  svc%d:
+    image: registry:5000/svc%d:1.%d
+    environment:
+      NAME: svc%d
+      PORT: "80%02d"
+    ports: ["%d:80"]
+    volumes: ["data%d:/data", "./conf:/etc/svc:ro"]
+    depends_on: [svc0]
+    healthcheck:
+      test: ["CMD", "curl", "-f", "http://localhost"]
+      interval: 10s
+`, i, i, i, i, i%100, 8000+i, i)
+	}
+	return []byte(b.String())
+}
+
+func BenchmarkParseCompose(b *testing.B) {
+	data := benchmarkCompose()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := GetConfigFromBytes(data, FormatYAML); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLazyConfig(b *testing.B) {
+	data := benchmarkCompose()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lazy, err := GetLazyConfigFromBytes(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := lazy.GetService("svc100"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"gopkg.in/yaml.v3"
+	"io"
+	"strings"
+)
+
+type Format int
+
+const (
+	FormatAuto Format = iota
+	FormatYAML
+	FormatJSON
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatAuto:
+		return "auto"
+	case FormatYAML:
+		return "yaml"
+	case FormatJSON:
+		return "json"
+	default:
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+}
+
+func GetConfigFromReader(r io.Reader, format Format) (*ComposeConfig, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return GetConfigFromBytes(content, format)
+}
+
+func GetConfigFromBytes(data []byte, format Format) (*ComposeConfig, error) {
+	if format == FormatAuto {
+		format = sniffFormat(data)
+	}
+	config := &ComposeConfig{}
+	var err error
+	switch format {
+	case FormatYAML:
+		err = yaml.Unmarshal(data, config)
+	case FormatJSON:
+		err = jsoniter.Unmarshal(data, config)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func sniffFormat(data []byte) Format {
+	trimmed := bytes.TrimLeft(data, " \t\r\n\ufeff")
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return FormatJSON
+	}
+	return FormatYAML
+}
+
+// withSource attaches the file path or URL a config was loaded from to err.
+// A ParseError gets it as its File; the messages of errors wrapping one were
+// made without it, so they get it as a prefix like any other error.
+func withSource(err error, source string) error {
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		parseErr.File = source
+		if err == error(parseErr) {
+			return err
+		}
+	}
+	return fmt.Errorf("%s: %w", source, err)
+}
+
+type ParseOptions struct {
+	Format Format
+	// FragmentLoader, when set, is called with the path of every fragment
+	// marker and the YAML or JSON it returns replaces the marker. Fragments
+	// may reference further fragments; paths are passed on as written.
+	FragmentLoader func(path string) ([]byte, error)
+	// Lookup, when set, resolves the variables interpolated into the file
+	// before it is decoded.
+	Lookup func(name string) (string, bool)
+	// Defaults, when set, are applied to every service once decoded.
+	Defaults *ServiceDefaults
+	// OnEvent, when set, receives what the loader does besides decoding:
+	// unknown fields it ignores, substitutions and applied defaults. It is
+	// also warned of environment variables with names that are not valid
+	// POSIX names or, except for JSON decoded as JSON, that are defined twice.
+	OnEvent func(Event)
+	// Strict reports everything wrong with the file at once in a MultiError:
+	// decoding goes on past an invalid service or section, warnings count as
+	// errors and the decoded config is validated.
+	Strict bool
+}
+
+// ParseComposeWithOptions parses a compose file like GetConfigFromBytes,
+// inlining fragments, interpolating and applying defaults as opts ask. These
+// steps work on the node tree, so JSON input is decoded as YAML when
+// FragmentLoader, Lookup or Strict is set.
+func ParseComposeWithOptions(data []byte, opts ParseOptions) (*ComposeConfig, error) {
+	format := opts.Format
+	if format == FormatAuto {
+		format = sniffFormat(data)
+	}
+	if format != FormatYAML && format != FormatJSON {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+	report := opts.OnEvent
+	if report == nil {
+		report = func(Event) {}
+	}
+	errs := &MultiError{}
+	if opts.Strict {
+		onEvent := report
+		report = func(event Event) {
+			onEvent(event)
+			if event.Level == EventWarn {
+				errs.Append(errors.New(event.String()))
+			}
+		}
+	}
+
+	var config *ComposeConfig
+	if format == FormatJSON && opts.FragmentLoader == nil && opts.Lookup == nil && !opts.Strict {
+		var err error
+		if config, err = GetConfigFromBytes(data, format); err != nil {
+			return nil, err
+		}
+	} else {
+		doc := &yaml.Node{}
+		if err := yaml.Unmarshal(data, doc); err != nil {
+			return nil, err
+		}
+		config = &ComposeConfig{}
+		if len(doc.Content) > 0 {
+			root := doc.Content[0]
+			if opts.FragmentLoader != nil {
+				if err := inlineFragments(root, opts.FragmentLoader, nil); err != nil {
+					return nil, err
+				}
+			}
+			if opts.Lookup != nil {
+				if err := interpolateNode(root, nil, opts.Lookup, report); err != nil {
+					return nil, err
+				}
+			}
+			if err := root.Decode(config); err != nil {
+				if opts.Strict {
+					return nil, decodeErrors(root, err)
+				}
+				return nil, err
+			}
+			reportUnknownTopLevel(root, report)
+			reportDuplicateEnvironment(root, report)
+		}
+	}
+
+	for _, name := range config.ServiceNames() {
+		serviceConf := (*config.Services)[name]
+		for _, key := range sortedKeys(serviceConf.RawExtra) {
+			if !strings.HasPrefix(key, "x-") {
+				report(Event{Level: EventWarn, Service: name, Field: key, Message: fmt.Sprintf("unknown field '%s' ignored", key)})
+			}
+		}
+		if serviceConf.Environment != nil {
+			for _, key := range serviceConf.Environment.Keys() {
+				if !regEnvName.MatchString(key) {
+					report(Event{Level: EventWarn, Service: name, Field: joinPath("environment", key), Message: fmt.Sprintf("environment variable name %q is not a valid POSIX name", key)})
+				}
+			}
+		}
+		if opts.Defaults != nil {
+			serviceConf.applyDefaults(*opts.Defaults, func(field string) {
+				report(Event{Level: EventDebug, Service: name, Field: field, Message: fmt.Sprintf("default %s applied", field)})
+			})
+		}
+	}
+	if opts.Strict {
+		errs.Append(config.Validate())
+		if err := errs.ErrorOrNil(); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// decodeErrors decodes every service and every other top-level section of
+// root on its own, so that one invalid entry does not hide the next. err, the
+// error of decoding root as a whole, is returned when none of them fails.
+func decodeErrors(root *yaml.Node, err error) error {
+	errs := &MultiError{}
+	if root.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			key, value := root.Content[i], root.Content[i+1]
+			if key.Value != "services" || value.Kind != yaml.MappingNode {
+				section := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{key, value}}
+				errs.Append(section.Decode(&ComposeConfig{}))
+				continue
+			}
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				appendServiceErrors(errs, value.Content[j].Value, value.Content[j+1].Decode(&ComposeServiceConfig{}))
+			}
+		}
+	}
+	if len(errs.Errors) == 0 {
+		return err
+	}
+	return errs
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStrictParseReportsEveryDecodeError(t *testing.T) {
+	data := `services:
+  web:
+    image: nginx
+    depends_on: db
+  db:
+    image: postgres
+    environment: A=1
+  cache:
+    image: redis
+`
+	_, err := ParseComposeWithOptions([]byte(data), ParseOptions{Strict: true})
+	var multiErr *MultiError
+	if !errors.As(err, &multiErr) || len(multiErr.Errors) != 2 {
+		t.Fatalf("got %v, want the errors of web and db", err)
+	}
+	if !errors.Is(multiErr.Errors[0], ErrInvalidDependsOn) || !errors.Is(multiErr.Errors[1], ErrInvalidEnvironment) {
+		t.Errorf("got %v, want the depends_on then the environment error", err)
+	}
+
+	// without Strict the first error is all there is
+	_, err = ParseComposeWithOptions([]byte(data), ParseOptions{})
+	if !errors.As(err, new(*ParseError)) || errors.As(err, &multiErr) {
+		t.Errorf("got %v, want the first parse error alone", err)
+	}
+}
+
+func TestStrictParseReportsWarningsAndValidation(t *testing.T) {
+	data := `services:
+  web:
+    image: nginx
+    pull_policy: sometimes
+    colour: blue
+  db:
+    image: postgres
+    shm_size: lots
+`
+	_, err := ParseComposeWithOptions([]byte(data), ParseOptions{Strict: true})
+	var multiErr *MultiError
+	if !errors.As(err, &multiErr) || len(multiErr.Errors) != 3 {
+		t.Fatalf("got %v, want the unknown field, pull_policy and shm_size errors", err)
+	}
+	if _, err := ParseComposeWithOptions([]byte(data), ParseOptions{}); err != nil {
+		t.Errorf("got %v without Strict", err)
+	}
+}
+
+func TestSniffedLoadKeepsTheFormatOfEachError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "compose")
+	if err := os.WriteFile(path, []byte("services:\n  web:\n    image: nginx\n    depends_on: db\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := GetConfigFromComposeFile(path)
+	var multiErr *MultiError
+	if !errors.As(err, &multiErr) || len(multiErr.Errors) != 2 {
+		t.Fatalf("got %v, want the yaml and json errors", err)
+	}
+	for i, format := range []string{"as yaml: ", "as json: "} {
+		message := multiErr.Errors[i].Error()
+		if !strings.HasPrefix(message, path+": "+format) {
+			t.Errorf("error %d is %q, want it to start with %s: %s", i, message, path, format)
+		}
+	}
+	var parseErr *ParseError
+	if !errors.As(multiErr.Errors[0], &parseErr) || parseErr.File != path || parseErr.Line != 4 {
+		t.Errorf("the yaml error has no location in %s: %v", path, multiErr.Errors[0])
+	}
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"github.com/docker/cli/cli/compose/types"
+	"strconv"
+)
+
+var knownLoggingDrivers = map[string]bool{
+	"none":       true,
+	"local":      true,
+	"json-file":  true,
+	"syslog":     true,
+	"journald":   true,
+	"gelf":       true,
+	"fluentd":    true,
+	"awslogs":    true,
+	"splunk":     true,
+	"etwlogs":    true,
+	"gcplogs":    true,
+	"logentries": true,
+}
+
+type ComposeLoggingConfig struct {
+	Driver  string            `json:"driver,omitempty" yaml:"driver,omitempty"`
+	Options map[string]string `json:"options,omitempty" yaml:"options,omitempty"`
+}
+
+func NewComposeLoggingConfig(logging *types.LoggingConfig) *ComposeLoggingConfig {
+	if logging == nil {
+		return nil
+	}
+	return (&ComposeLoggingConfig{Driver: logging.Driver, Options: logging.Options}).Clone()
+}
+
+func (l *ComposeLoggingConfig) ToDocker() *types.LoggingConfig {
+	if l == nil {
+		return nil
+	}
+	clone := l.Clone()
+	return &types.LoggingConfig{Driver: clone.Driver, Options: clone.Options}
+}
+
+func (l *ComposeLoggingConfig) Clone() *ComposeLoggingConfig {
+	if l == nil {
+		return nil
+	}
+	clone := &ComposeLoggingConfig{Driver: l.Driver}
+	if l.Options != nil {
+		clone.Options = make(map[string]string, len(l.Options))
+		for key, value := range l.Options {
+			clone.Options[key] = value
+		}
+	}
+	return clone
+}
+
+// MaxSize returns the max-size option of the json-file and local drivers in
+// bytes, or 0 when it is not set.
+func (l *ComposeLoggingConfig) MaxSize() (int64, error) {
+	value, ok := l.Options["max-size"]
+	if !ok {
+		return 0, nil
+	}
+	size, err := ParseBytes(value)
+	if err != nil {
+		return 0, fmt.Errorf("logging max-size: %w", err)
+	}
+	return size, nil
+}
+
+// MaxFile returns the max-file option of the json-file and local drivers, or
+// 0 when it is not set.
+func (l *ComposeLoggingConfig) MaxFile() (int, error) {
+	value, ok := l.Options["max-file"]
+	if !ok {
+		return 0, nil
+	}
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 1 {
+		return 0, fmt.Errorf("logging max-file: invalid count %q", value)
+	}
+	return count, nil
+}
+
+func (l *ComposeLoggingConfig) Validate() error {
+	errs := &MultiError{}
+	if l.Driver != "" && !knownLoggingDrivers[l.Driver] {
+		errs.Append(fmt.Errorf("unknown logging driver: %s", l.Driver))
+	}
+	if _, err := l.MaxSize(); err != nil {
+		errs.Append(err)
+	}
+	if _, err := l.MaxFile(); err != nil {
+		errs.Append(err)
+	}
+	return errs.ErrorOrNil()
+}
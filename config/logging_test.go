@@ -0,0 +1,32 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLoggingNilConversions(t *testing.T) {
+	var logging *ComposeLoggingConfig
+	if clone := logging.Clone(); clone != nil {
+		t.Errorf("Clone of nil = %+v", clone)
+	}
+	if docker := logging.ToDocker(); docker != nil {
+		t.Errorf("ToDocker of nil = %+v", docker)
+	}
+	if back := NewComposeLoggingConfig(logging.ToDocker()); back != nil {
+		t.Errorf("round trip of nil = %+v", back)
+	}
+}
+
+func TestLoggingCloneIsDeep(t *testing.T) {
+	logging := &ComposeLoggingConfig{Driver: "json-file", Options: map[string]string{"max-size": "10m"}}
+	clone := logging.Clone()
+	clone.Options["max-size"] = "1m"
+	if logging.Options["max-size"] != "10m" {
+		t.Errorf("changing the clone changed the original: %v", logging.Options)
+	}
+	docker := logging.ToDocker()
+	if !reflect.DeepEqual(NewComposeLoggingConfig(docker), logging) {
+		t.Errorf("round trip of %+v gave %+v", logging, docker)
+	}
+}
@@ -0,0 +1,320 @@
+package config
+
+import (
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"strings"
+)
+
+// sequences that compose merges by appending the override's missing entries;
+// every other sequence is replaced as a whole. The dns and expose keys are not
+// modeled and merged in RawExtra.
+var mergeUnionFields = map[string]bool{
+	"security_opt": true,
+	"cap_add":      true,
+	"cap_drop":     true,
+	"dns":          true,
+	"dns_search":   true,
+	"dns_opt":      true,
+	"expose":       true,
+}
+
+// Merge applies override on top of conf following the compose multi-file
+// rules: scalars are replaced when set, mappings are merged key by key and
+// sequences are either appended to or replaced depending on the field. A
+// mapping entry without a value, such as a network declared as "back:", keeps
+// the base entry.
+//
+// Optional flags are pointers, such as privileged or read_only, so that an
+// override can set them back to false; external is replaced as a whole, and
+// service volumes are replaced by target along with their read_only flag.
+// The remaining plain booleans, healthcheck disable and the external flag of
+// secrets and configs, can only be turned on by an override.
+func (conf *ComposeConfig) Merge(override *ComposeConfig) {
+	if override == nil {
+		return
+	}
+	if conf.Services == nil && override.Services != nil {
+		conf.Services = &ComposeServicesConfig{}
+	}
+	mergeValue(reflect.ValueOf(conf).Elem(), reflect.ValueOf(override).Elem(), "")
+	if conf.Services != nil {
+		for name, serviceConf := range *conf.Services {
+			serviceConf.ServiceName = name
+		}
+	}
+}
+
+func isMergeablePointer(v reflect.Value) bool {
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return false
+	}
+	return v.Elem().Kind() == reflect.Struct || v.Elem().Kind() == reflect.Map
+}
+
+func mergeValue(dst, src reflect.Value, field string) {
+	if src.IsZero() {
+		return
+	}
+	switch src.Kind() {
+	case reflect.Struct:
+		for i := 0; i < src.NumField(); i++ {
+			structField := src.Type().Field(i)
+			if !structField.IsExported() {
+				continue
+			}
+			mergeValue(dst.Field(i), src.Field(i), yamlFieldName(structField))
+		}
+	case reflect.Pointer:
+		if env, ok := src.Interface().(*ComposeEnvironmentConfig); ok {
+			dst.Set(reflect.ValueOf(mergeEnvironment(dst.Interface().(*ComposeEnvironmentConfig), env)))
+			return
+		}
+		if _, ok := src.Interface().(*ComposeExternalConfig); ok {
+			dst.Set(src)
+			return
+		}
+		if dst.IsNil() {
+			dst.Set(src)
+			return
+		}
+		switch src.Elem().Kind() {
+		case reflect.Struct, reflect.Map:
+			mergeValue(dst.Elem(), src.Elem(), field)
+		default:
+			dst.Set(src)
+		}
+	case reflect.Map:
+		if extra, ok := src.Interface().(map[string]yaml.Node); ok {
+			dst.Set(reflect.ValueOf(mergeRawExtra(dst.Interface().(map[string]yaml.Node), extra)))
+			return
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMapWithSize(src.Type(), src.Len()))
+		}
+		iter := src.MapRange()
+		for iter.Next() {
+			existing := dst.MapIndex(iter.Key())
+			value := iter.Value()
+			if existing.IsValid() && value.Kind() == reflect.Pointer && value.IsNil() {
+				continue
+			}
+			if existing.IsValid() && isMergeablePointer(existing) {
+				mergeValue(existing, value, field)
+				continue
+			}
+			dst.SetMapIndex(iter.Key(), value)
+		}
+	case reflect.Slice:
+		switch {
+		case field == "volumes" && src.Type() == reflect.TypeOf(ComposeServiceVolumesConfig{}):
+			dst.Set(reflect.ValueOf(mergeVolumes(dst.Interface().(ComposeServiceVolumesConfig), src.Interface().(ComposeServiceVolumesConfig))))
+		case field == "networks":
+			dst.Set(reflect.ValueOf(mergeServiceNetworks(dst.Interface().(ComposeServiceNetworksConfig), src.Interface().(ComposeServiceNetworksConfig))))
+		case field == "ports":
+			dst.Set(reflect.ValueOf(mergePorts(dst.Interface().(ComposeServicePortsConfig), src.Interface().(ComposeServicePortsConfig))))
+		case field == "env_file":
+			dst.Set(reflect.ValueOf(mergeEnvFiles(dst.Interface().(ComposeEnvFileConfig), src.Interface().(ComposeEnvFileConfig))))
+		case field == "secrets":
+			dst.Set(reflect.ValueOf(mergeServiceSecrets(dst.Interface().(ComposeServiceSecretsConfig), src.Interface().(ComposeServiceSecretsConfig))))
+		case mergeUnionFields[field] && src.Type().Elem().Kind() == reflect.String:
+			dst.Set(unionStrings(dst, src))
+		default:
+			dst.Set(src)
+		}
+	default:
+		dst.Set(src)
+	}
+}
+
+func yamlFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "" {
+		return strings.ToLower(field.Name)
+	}
+	return name
+}
+
+func unionStrings(dst, src reflect.Value) reflect.Value {
+	result := reflect.MakeSlice(dst.Type(), 0, dst.Len()+src.Len())
+	seen := map[string]bool{}
+	for _, list := range []reflect.Value{dst, src} {
+		for i := 0; i < list.Len(); i++ {
+			if item := list.Index(i); !seen[item.String()] {
+				seen[item.String()] = true
+				result = reflect.Append(result, item)
+			}
+		}
+	}
+	return result
+}
+
+// mergeRawExtra merges the unmodeled service keys: the union fields get the
+// override's missing scalars appended, in their list or single value form,
+// and every other key is replaced.
+func mergeRawExtra(base, override map[string]yaml.Node) map[string]yaml.Node {
+	result := make(map[string]yaml.Node, len(base)+len(override))
+	for key, value := range base {
+		result[key] = value
+	}
+	for key, value := range override {
+		existing, ok := result[key]
+		if !ok || !mergeUnionFields[key] {
+			result[key] = value
+			continue
+		}
+		baseItems, baseOK := scalarItems(&existing)
+		overrideItems, overrideOK := scalarItems(&value)
+		if !baseOK || !overrideOK {
+			result[key] = value
+			continue
+		}
+		union := yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		seen := map[string]bool{}
+		for _, item := range append(baseItems, overrideItems...) {
+			if !seen[item.Value] {
+				seen[item.Value] = true
+				union.Content = append(union.Content, item)
+			}
+		}
+		result[key] = union
+	}
+	return result
+}
+
+// scalarItems returns the items of a sequence of scalars, or a lone scalar as
+// a single item.
+func scalarItems(node *yaml.Node) ([]*yaml.Node, bool) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return []*yaml.Node{node}, true
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return nil, false
+			}
+		}
+		return node.Content, true
+	}
+	return nil, false
+}
+
+// mergeVolumes merges volumes by container path, the override replacing a
+// base entry mounted at the same target.
+func mergeVolumes(base, override ComposeServiceVolumesConfig) ComposeServiceVolumesConfig {
+	result := append(ComposeServiceVolumesConfig{}, base...)
+	index := map[string]int{}
+	for i, volume := range result {
+		if mount, err := volume.Mount(); err == nil {
+			index[mount.Target] = i
+		}
+	}
+	for _, volume := range override {
+		mount, err := volume.Mount()
+		if err == nil {
+			if i, ok := index[mount.Target]; ok {
+				result[i] = volume
+				continue
+			}
+			index[mount.Target] = len(result)
+		}
+		result = append(result, volume)
+	}
+	return result
+}
+
+func mergeServiceSecrets(base, override ComposeServiceSecretsConfig) ComposeServiceSecretsConfig {
+	result := append(ComposeServiceSecretsConfig{}, base...)
+	index := map[string]int{}
+	for i, secret := range result {
+		index[secret.Source] = i
+	}
+	for _, secret := range override {
+		if i, ok := index[secret.Source]; ok {
+			result[i] = secret
+			continue
+		}
+		index[secret.Source] = len(result)
+		result = append(result, secret)
+	}
+	return result
+}
+
+func mergeServiceNetworks(base, override ComposeServiceNetworksConfig) ComposeServiceNetworksConfig {
+	result := append(ComposeServiceNetworksConfig{}, base...)
+	index := map[string]int{}
+	for i, network := range result {
+		index[network.Name] = i
+	}
+	for _, network := range override {
+		if i, ok := index[network.Name]; ok {
+			if !network.isShort() {
+				result[i] = network
+			}
+			continue
+		}
+		index[network.Name] = len(result)
+		result = append(result, network)
+	}
+	return result
+}
+
+// mergeEnvironment sets the override's variables on top of base, except that
+// a null override removes the variable instead.
+func mergeEnvironment(base, override *ComposeEnvironmentConfig) *ComposeEnvironmentConfig {
+	result := &ComposeEnvironmentConfig{Values: map[string]string{}}
+	if base != nil {
+		for _, key := range base.Keys() {
+			if base.IsNull(key) {
+				result.addNull(key)
+			} else {
+				result.add(key, base.Values[key])
+			}
+		}
+	}
+	for _, key := range override.Keys() {
+		if override.IsNull(key) {
+			result.Delete(key)
+		} else {
+			result.add(key, override.Values[key])
+		}
+	}
+	return result
+}
+
+// mergeEnvFiles appends the override's env files, keeping the position of
+// files already listed but taking the override's required flag.
+func mergeEnvFiles(base, override ComposeEnvFileConfig) ComposeEnvFileConfig {
+	result := append(ComposeEnvFileConfig{}, base...)
+	index := map[string]int{}
+	for i, entry := range result {
+		index[entry.Path] = i
+	}
+	for _, entry := range override {
+		if i, ok := index[entry.Path]; ok {
+			result[i] = entry
+			continue
+		}
+		index[entry.Path] = len(result)
+		result = append(result, entry)
+	}
+	return result
+}
+
+// mergePorts appends the override's ports that are not listed yet, in either
+// syntax.
+func mergePorts(base, override ComposeServicePortsConfig) ComposeServicePortsConfig {
+	result := append(ComposeServicePortsConfig{}, base...)
+	seen := map[string]bool{}
+	for _, port := range result {
+		key, _ := port.MarshalJSON()
+		seen[string(key)] = true
+	}
+	for _, port := range override {
+		if key, _ := port.MarshalJSON(); !seen[string(key)] {
+			seen[string(key)] = true
+			result = append(result, port)
+		}
+	}
+	return result
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"github.com/docker/cli/cli/compose/types"
+	"reflect"
+	"testing"
+)
+
+func TestMergeOverrideSetsBoolsToFalse(t *testing.T) {
+	base := mustParse(t, `services:
+  web:
+    image: nginx
+    privileged: true
+    read_only: true
+`)
+	override := mustParse(t, `services:
+  web:
+    privileged: false
+`)
+	base.Merge(override)
+	web := (*base.Services)["web"]
+	if web.IsPrivileged() {
+		t.Error("privileged: false in the override did not apply")
+	}
+	if !web.IsReadOnly() {
+		t.Error("read_only changed although the override does not set it")
+	}
+}
+
+func TestMergeUnionsListsAndDeduplicatesPorts(t *testing.T) {
+	base := mustParse(t, `services:
+  web:
+    image: nginx
+    cap_add: [NET_ADMIN]
+    dns: 8.8.8.8
+    ports: ["8080:80", "443:443"]
+`)
+	override := mustParse(t, `services:
+  web:
+    cap_add: [NET_ADMIN, SYS_TIME]
+    dns: [1.1.1.1]
+    ports: ["443:443", "9000:9000"]
+`)
+	base.Merge(override)
+	web := (*base.Services)["web"]
+	if want := []string{"NET_ADMIN", "SYS_TIME"}; !reflect.DeepEqual(web.CapAdd, want) {
+		t.Errorf("cap_add = %v, want %v", web.CapAdd, want)
+	}
+	dns := web.RawExtra["dns"]
+	var servers []string
+	if err := dns.Decode(&servers); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"8.8.8.8", "1.1.1.1"}; !reflect.DeepEqual(servers, want) {
+		t.Errorf("dns = %v, want %v", servers, want)
+	}
+	var ports []string
+	for _, port := range web.Ports {
+		ports = append(ports, port.String())
+	}
+	if want := []string{"8080:80", "443:443", "9000:9000"}; !reflect.DeepEqual(ports, want) {
+		t.Errorf("ports = %v, want %v", ports, want)
+	}
+}
+
+func TestMergeReplacesVolumesByTarget(t *testing.T) {
+	base := mustParse(t, `services:
+  web:
+    image: nginx
+    volumes:
+      - ./html:/usr/share/nginx/html:ro
+      - type: bind
+        source: ./conf
+        target: /etc/nginx
+        read_only: true
+`)
+	override := mustParse(t, `services:
+  web:
+    volumes:
+      - type: bind
+        source: ./conf
+        target: /etc/nginx
+        read_only: false
+      - cache:/var/cache/nginx
+`)
+	base.Merge(override)
+	volumes := (*base.Services)["web"].Volumes
+	if len(volumes) != 3 {
+		t.Fatalf("got %d volumes, want 3", len(volumes))
+	}
+	if volumes[0].Spec != "./html:/usr/share/nginx/html:ro" || volumes[2].Spec != "cache:/var/cache/nginx" {
+		t.Errorf("got %+v, want the base volume first and the new one last", volumes)
+	}
+	if conf := volumes[1].Long; conf == nil || conf.Target != "/etc/nginx" || conf.ReadOnly {
+		t.Errorf("/etc/nginx = %+v, want the read-write mount of the override", conf)
+	}
+}
+
+func TestMergeEnvironmentAndLabels(t *testing.T) {
+	base := mustParse(t, `services:
+  web:
+    image: nginx
+    environment:
+      A: "1"
+      B: "2"
+    labels:
+      team: web
+      tier: front
+`)
+	override := mustParse(t, `services:
+  web:
+    environment:
+      B: "3"
+      C: "4"
+    labels:
+      tier: edge
+`)
+	base.Merge(override)
+	web := (*base.Services)["web"]
+	if want := map[string]string{"A": "1", "B": "3", "C": "4"}; !reflect.DeepEqual(web.Environment.Values, want) {
+		t.Errorf("environment = %v, want %v", web.Environment.Values, want)
+	}
+	if want := (types.Labels{"team": "web", "tier": "edge"}); !reflect.DeepEqual(*web.Labels, want) {
+		t.Errorf("labels = %v, want %v", *web.Labels, want)
+	}
+}
+
+func TestMergeKeepsEntriesWithoutValue(t *testing.T) {
+	base := mustParse(t, `services:
+  web:
+    image: nginx
+networks:
+  back:
+    driver: overlay
+  front:
+    external: true
+`)
+	override := mustParse(t, `services:
+  web:
+    image: nginx
+networks:
+  back:
+  front:
+    external: false
+`)
+	base.Merge(override)
+	networks := base.Networks
+	if back := networks["back"]; back == nil || back.Driver != "overlay" {
+		t.Errorf("back = %+v, want the base definition", back)
+	}
+	if front := networks["front"]; front == nil || front.External == nil || front.External.External {
+		t.Errorf("front = %+v, want external: false from the override", front)
+	}
+}
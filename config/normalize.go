@@ -0,0 +1,222 @@
+package config
+
+import (
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type NormalizeOptions struct {
+	// WorkingDir resolves relative bind mount sources; they are kept as is
+	// when empty.
+	WorkingDir string
+	// Lookup enables variable interpolation when set.
+	Lookup func(string) (string, bool)
+}
+
+// Normalize rewrites the config into a canonical form close to the output of
+// `docker compose config`, so that equivalent configs compare equal. Ports
+// and volumes come out in the long syntax.
+func (conf *ComposeConfig) Normalize(opts NormalizeOptions) error {
+	if opts.Lookup != nil {
+		if err := conf.Interpolate(opts.Lookup); err != nil {
+			return err
+		}
+	}
+	conf.NormalizeScaleToDeploy()
+	conf.DeduplicateLists()
+	for _, name := range conf.ServiceNames() {
+		serviceConf := (*conf.Services)[name]
+		serviceConf.normalize(opts)
+	}
+	for _, networkConf := range conf.Networks {
+		if networkConf != nil && networkConf.Labels != nil && len(*networkConf.Labels) == 0 {
+			networkConf.Labels = nil
+		}
+	}
+	for _, volumeConf := range conf.Volumes {
+		if volumeConf != nil && volumeConf.Labels != nil && len(*volumeConf.Labels) == 0 {
+			volumeConf.Labels = nil
+		}
+	}
+	return nil
+}
+
+// DeduplicateLists removes repeated entries from the list fields of every
+// service, keeping the first occurrence. Ports and volumes are compared by
+// what they mean, so 80:80 and 80:80/tcp count as the same port.
+func (conf *ComposeConfig) DeduplicateLists() {
+	for _, name := range conf.ServiceNames() {
+		(*conf.Services)[name].DeduplicateLists()
+	}
+}
+
+func (serviceConf *ComposeServiceConfig) DeduplicateLists() {
+	same := func(s string) string { return s }
+	serviceConf.Networks = dedupeBy(serviceConf.Networks, func(network *ComposeServiceNetworkConfig) string {
+		return network.Name
+	})
+	serviceConf.Ports = dedupeBy(serviceConf.Ports, func(port ComposeServicePortConfig) string {
+		bindings, err := port.Bindings()
+		if err != nil {
+			return port.String()
+		}
+		return fmt.Sprint(bindings)
+	})
+	serviceConf.Volumes = dedupeBy(serviceConf.Volumes, func(volume ComposeServiceVolumeConfig) string {
+		mount, err := volume.Mount()
+		if err != nil {
+			return volume.String()
+		}
+		// the long syntax drops the rw default and orders the options
+		long, _ := jsoniter.Marshal(mount.LongSyntax())
+		return string(long)
+	})
+	serviceConf.Secrets = dedupeBy(serviceConf.Secrets, func(secret *ComposeServiceSecretConfig) string {
+		mode := ""
+		if secret.Mode != nil {
+			mode = strconv.FormatUint(uint64(*secret.Mode), 8)
+		}
+		return strings.Join([]string{secret.Source, secret.Target, secret.UID, secret.GID, mode}, "\x00")
+	})
+	serviceConf.SecurityOpt = dedupeBy(serviceConf.SecurityOpt, same)
+	serviceConf.CapAdd = dedupeBy(serviceConf.CapAdd, same)
+	serviceConf.CapDrop = dedupeBy(serviceConf.CapDrop, same)
+	serviceConf.EnvFile = dedupeBy(serviceConf.EnvFile, func(entry ComposeEnvFileEntry) string { return entry.Path })
+}
+
+// dedupeBy keeps the first item of list for every key.
+func dedupeBy[S ~[]E, E any](list S, key func(E) string) S {
+	if len(list) < 2 {
+		return list
+	}
+	seen := make(map[string]bool, len(list))
+	result := make(S, 0, len(list))
+	for _, item := range list {
+		if k := key(item); !seen[k] {
+			seen[k] = true
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func (serviceConf *ComposeServiceConfig) normalize(opts NormalizeOptions) {
+	if serviceConf.DependsOn != nil {
+		for _, dep := range *serviceConf.DependsOn {
+			if dep.Condition == "" {
+				dep.Condition = "service_started"
+			}
+		}
+	}
+
+	// ports and volumes are written in the long syntax, one port per entry
+	// as docker compose config does; entries that do not parse are kept for
+	// Validate to report
+	ports := make(ComposeServicePortsConfig, 0, len(serviceConf.Ports))
+	for _, port := range serviceConf.Ports {
+		bindings, err := port.Bindings()
+		if err != nil {
+			ports = append(ports, port)
+			continue
+		}
+		for _, binding := range bindings {
+			long := &ComposePortConfig{Target: binding.Target, HostIP: binding.HostIP, Protocol: binding.Protocol, Mode: binding.Mode}
+			if port.Long != nil {
+				long.Name, long.AppProtocol = port.Long.Name, port.Long.AppProtocol
+			}
+			if binding.PublishedEnd > 0 {
+				long.Published = ComposePublishedPort(formatPortRange(binding.Published, binding.PublishedEnd))
+			} else if binding.Published > 0 {
+				long.Published = ComposePublishedPort(strconv.Itoa(binding.Published))
+			}
+			if long.Mode == "" {
+				long.Mode = PortModeIngress
+			}
+			ports = append(ports, ComposeServicePortConfig{Long: long})
+		}
+	}
+	if len(serviceConf.Ports) > 0 {
+		serviceConf.Ports = ports
+	}
+
+	for i, volume := range serviceConf.Volumes {
+		mount, err := volume.Mount()
+		if err != nil {
+			continue
+		}
+		long := mount.LongSyntax()
+		if long.Type == VolumeTypeBind {
+			long.Source = resolveHostPath(opts.WorkingDir, long.Source)
+			// the short syntax creates missing host paths
+			if long.Bind == nil {
+				long.Bind = &VolumeBindOptions{}
+			}
+			if long.Bind.CreateHostPath == nil {
+				createHostPath := true
+				long.Bind.CreateHostPath = &createHostPath
+			}
+		}
+		serviceConf.Volumes[i] = ComposeServiceVolumeConfig{Long: long}
+	}
+
+	sort.SliceStable(serviceConf.Networks, func(i, j int) bool {
+		return serviceConf.Networks[i].Name < serviceConf.Networks[j].Name
+	})
+	sort.SliceStable(serviceConf.Ports, func(i, j int) bool {
+		return serviceConf.Ports[i].String() < serviceConf.Ports[j].String()
+	})
+	sort.SliceStable(serviceConf.Volumes, func(i, j int) bool {
+		return serviceConf.Volumes[i].String() < serviceConf.Volumes[j].String()
+	})
+	sort.Strings(serviceConf.SecurityOpt)
+	sort.SliceStable(serviceConf.Secrets, func(i, j int) bool {
+		return serviceConf.Secrets[i].Source < serviceConf.Secrets[j].Source
+	})
+
+	if serviceConf.Environment != nil && serviceConf.Environment.Len() == 0 {
+		serviceConf.Environment = nil
+	}
+	if serviceConf.Annotations != nil && serviceConf.Annotations.Len() == 0 {
+		serviceConf.Annotations = nil
+	}
+	if serviceConf.Labels != nil && len(*serviceConf.Labels) == 0 {
+		serviceConf.Labels = nil
+	}
+	if serviceConf.DependsOn != nil && len(*serviceConf.DependsOn) == 0 {
+		serviceConf.DependsOn = nil
+	}
+	if serviceConf.Healthcheck != nil && reflect.ValueOf(*serviceConf.Healthcheck).IsZero() {
+		serviceConf.Healthcheck = nil
+	}
+	if serviceConf.Deploy != nil && reflect.ValueOf(*serviceConf.Deploy).IsZero() {
+		serviceConf.Deploy = nil
+	}
+	if serviceConf.PullPolicy == string(PullPolicyMissing) {
+		serviceConf.PullPolicy = ""
+	}
+}
+
+// resolveHostPath expands ~ and makes a ./ or ../ path absolute against
+// baseDir. Anything else, Windows paths included, is returned unchanged.
+func resolveHostPath(baseDir, path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+		return path
+	}
+	if !strings.HasPrefix(path, ".") || baseDir == "" {
+		return path
+	}
+	absDir, err := filepath.Abs(baseDir)
+	if err != nil {
+		return filepath.Join(baseDir, path)
+	}
+	return filepath.Join(absDir, path)
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeExpandsPortsAndVolumes(t *testing.T) {
+	conf := mustParse(t, `services:
+  web:
+    image: nginx
+    ports:
+      - "8080:80"
+      - "127.0.0.1:53:53/udp"
+      - "9000"
+    volumes:
+      - ./a:/b:ro
+      - data:/data
+      - /anon
+`)
+	if err := conf.Normalize(NormalizeOptions{WorkingDir: "/project"}); err != nil {
+		t.Fatal(err)
+	}
+	web := (*conf.Services)["web"]
+
+	wantPorts := []ComposePortConfig{
+		{Target: 53, HostIP: "127.0.0.1", Published: "53", Protocol: "udp", Mode: PortModeIngress},
+		{Target: 80, Published: "8080", Protocol: "tcp", Mode: PortModeIngress},
+		{Target: 9000, Protocol: "tcp", Mode: PortModeIngress},
+	}
+	if len(web.Ports) != len(wantPorts) {
+		t.Fatalf("got %d ports, want %d", len(web.Ports), len(wantPorts))
+	}
+	for i, want := range wantPorts {
+		if web.Ports[i].Long == nil {
+			t.Errorf("port %d is still in the short syntax: %s", i, web.Ports[i].Spec)
+			continue
+		}
+		if !reflect.DeepEqual(*web.Ports[i].Long, want) {
+			t.Errorf("port %d = %+v, want %+v", i, *web.Ports[i].Long, want)
+		}
+	}
+
+	createHostPath := true
+	wantVolumes := []VolumeMount{
+		{Type: VolumeTypeVolume, Target: "/anon"},
+		{Type: VolumeTypeBind, Source: "/project/a", Target: "/b", ReadOnly: true, Bind: &VolumeBindOptions{CreateHostPath: &createHostPath}},
+		{Type: VolumeTypeVolume, Source: "data", Target: "/data"},
+	}
+	if len(web.Volumes) != len(wantVolumes) {
+		t.Fatalf("got %d volumes, want %d", len(web.Volumes), len(wantVolumes))
+	}
+	for i, want := range wantVolumes {
+		if web.Volumes[i].Long == nil {
+			t.Errorf("volume %d is still in the short syntax: %s", i, web.Volumes[i].Spec)
+			continue
+		}
+		if !reflect.DeepEqual(*web.Volumes[i].Long, want) {
+			t.Errorf("volume %d = %+v, want %+v", i, *web.Volumes[i].Long, want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// ReferencedHostPaths returns every host path the project reads: bind mount
+// sources, env files, build contexts and secret/config files, resolved against
+// baseDir.
+func (conf *ComposeConfig) ReferencedHostPaths(baseDir string) []string {
+	paths := map[string]bool{}
+	add := func(path string) {
+		if path != "" {
+			paths[absHostPath(baseDir, path)] = true
+		}
+	}
+	for _, name := range conf.ServiceNames() {
+		serviceConf := (*conf.Services)[name]
+		for _, volume := range serviceConf.Volumes {
+			if mount, err := volume.Mount(); err == nil && mount.Type == VolumeTypeBind {
+				add(mount.Source)
+			}
+		}
+		for _, envFile := range serviceConf.EnvFile {
+			add(envFile.Path)
+		}
+		if serviceConf.Build != nil && !isRemoteContext(serviceConf.Build.Context) {
+			add(serviceConf.Build.Context)
+		}
+	}
+	for _, secretConf := range conf.Secrets {
+		if secretConf != nil {
+			add(secretConf.File)
+		}
+	}
+	for _, configConf := range conf.Configs {
+		if configConf != nil {
+			add(configConf.File)
+		}
+	}
+	return sortedKeys(paths)
+}
+
+// absHostPath resolves path against baseDir, leaving absolute, Windows and UNC
+// paths untouched.
+func absHostPath(baseDir, path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		return resolveHostPath(baseDir, path)
+	}
+	if filepath.IsAbs(path) || isHostPath(path) && !strings.HasPrefix(path, ".") {
+		return path
+	}
+	if absDir, err := filepath.Abs(baseDir); err == nil {
+		baseDir = absDir
+	}
+	return filepath.Join(baseDir, path)
+}
+
+func isRemoteContext(context string) bool {
+	return strings.Contains(context, "://") || strings.HasPrefix(context, "git@")
+}
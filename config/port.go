@@ -0,0 +1,404 @@
+package config
+
+import (
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"gopkg.in/yaml.v3"
+	"strconv"
+	"strings"
+)
+
+type PortBinding struct {
+	HostIP string
+	// Published is 0 when docker picks an ephemeral host port.
+	Published int
+	// PublishedEnd is set when docker picks the host port from the range
+	// Published to PublishedEnd, as for 8000-8010:80.
+	PublishedEnd int
+	Target       int
+	Protocol     string
+	// Mode is host or ingress, only set by the long syntax.
+	Mode string
+}
+
+const (
+	PortModeHost    = "host"
+	PortModeIngress = "ingress"
+)
+
+// ComposePortConfig is a port in the long syntax.
+type ComposePortConfig struct {
+	Name        string               `json:"name,omitempty" yaml:"name,omitempty"`
+	Target      int                  `json:"target" yaml:"target"`
+	HostIP      string               `json:"host_ip,omitempty" yaml:"host_ip,omitempty"`
+	Published   ComposePublishedPort `json:"published,omitempty" yaml:"published,omitempty"`
+	Protocol    string               `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+	AppProtocol string               `json:"app_protocol,omitempty" yaml:"app_protocol,omitempty"`
+	Mode        string               `json:"mode,omitempty" yaml:"mode,omitempty"`
+}
+
+// ComposePublishedPort is a published port or range, written as a number or
+// a string.
+type ComposePublishedPort string
+
+func (p *ComposePublishedPort) UnmarshalJSON(data []byte) error {
+	var n int64
+	if err := jsoniter.Unmarshal(data, &n); err == nil {
+		*p = ComposePublishedPort(strconv.FormatInt(n, 10))
+		return nil
+	}
+	return jsoniter.Unmarshal(data, (*string)(p))
+}
+
+// Bindings returns the port as a binding, with an empty protocol read as tcp.
+func (port *ComposePortConfig) Bindings() ([]PortBinding, error) {
+	if _, err := parsePort(strconv.Itoa(port.Target)); err != nil {
+		return nil, fmt.Errorf("invalid port target: %w", err)
+	}
+	binding := PortBinding{HostIP: port.HostIP, Target: port.Target, Protocol: strings.ToLower(port.Protocol), Mode: port.Mode}
+	switch binding.Protocol {
+	case "":
+		binding.Protocol = "tcp"
+	case "tcp", "udp", "sctp":
+	default:
+		return nil, fmt.Errorf("invalid port %d: unknown protocol %s", port.Target, port.Protocol)
+	}
+	switch port.Mode {
+	case "", PortModeHost, PortModeIngress:
+	default:
+		return nil, fmt.Errorf("invalid port %d: unknown mode %s", port.Target, port.Mode)
+	}
+	if port.Published != "" {
+		start, end, err := parsePortRange(string(port.Published))
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %d: %w", port.Target, err)
+		}
+		binding.Published = start
+		if start != end {
+			binding.PublishedEnd = end
+		}
+	}
+	return []PortBinding{binding}, nil
+}
+
+// ComposeServicePortConfig is one entry of the service ports, either Spec in
+// the short syntax or Long in the long syntax.
+type ComposeServicePortConfig struct {
+	Spec string
+	Long *ComposePortConfig
+}
+
+func (p ComposeServicePortConfig) Bindings() ([]PortBinding, error) {
+	if p.Long != nil {
+		return p.Long.Bindings()
+	}
+	return ParsePortSpec(p.Spec)
+}
+
+// String returns the entry in the short syntax, dropping the settings only
+// the long syntax has.
+func (p ComposeServicePortConfig) String() string {
+	if p.Long == nil {
+		return p.Spec
+	}
+	bindings, err := p.Long.Bindings()
+	if err != nil {
+		return strconv.Itoa(p.Long.Target)
+	}
+	return FormatPortSpec(bindings)[0]
+}
+
+func (p *ComposeServicePortConfig) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		p.Spec, p.Long = node.Value, nil
+		return nil
+	case yaml.MappingNode:
+		long := &ComposePortConfig{}
+		if err := node.Decode(long); err != nil {
+			return err
+		}
+		if long.Target == 0 {
+			return newParseError(node, "ports", "missing target")
+		}
+		p.Spec, p.Long = "", long
+		return nil
+	}
+	return newParseError(node, "ports", "invalid ports format")
+}
+
+func (p ComposeServicePortConfig) MarshalYAML() (any, error) {
+	if p.Long != nil {
+		return p.Long, nil
+	}
+	return p.Spec, nil
+}
+
+func (p *ComposeServicePortConfig) UnmarshalJSON(data []byte) error {
+	var n int64
+	if err := jsoniter.Unmarshal(data, &n); err == nil {
+		p.Spec, p.Long = strconv.FormatInt(n, 10), nil
+		return nil
+	}
+	if err := jsoniter.Unmarshal(data, &p.Spec); err == nil {
+		p.Long = nil
+		return nil
+	}
+	long := &ComposePortConfig{}
+	if err := jsoniter.Unmarshal(data, long); err != nil {
+		return fmt.Errorf("invalid ports format: %w", err)
+	}
+	if long.Target == 0 {
+		return fmt.Errorf("invalid ports format: missing target")
+	}
+	p.Spec, p.Long = "", long
+	return nil
+}
+
+func (p ComposeServicePortConfig) MarshalJSON() ([]byte, error) {
+	if p.Long != nil {
+		return jsoniter.Marshal(p.Long)
+	}
+	return jsoniter.Marshal(p.Spec)
+}
+
+type ComposeServicePortsConfig []ComposeServicePortConfig
+
+// ParsePortSpec parses a short port syntax entry such as "3000-3005",
+// "127.0.0.1:8001:8001", "[::1]:8080:80" or "6060:6060/udp", expanding ranges
+// into one binding per port. A published range for a single target, such as
+// "8000-8010:80", stays one binding with PublishedEnd set.
+func ParsePortSpec(s string) ([]PortBinding, error) {
+	spec, protocol := s, "tcp"
+	if i := strings.LastIndex(spec, "/"); i >= 0 {
+		spec, protocol = spec[:i], strings.ToLower(spec[i+1:])
+		switch protocol {
+		case "tcp", "udp", "sctp":
+		default:
+			return nil, fmt.Errorf("invalid port spec %q: unknown protocol %s", s, protocol)
+		}
+	}
+
+	hostIP := ""
+	if strings.HasPrefix(spec, "[") {
+		end := strings.Index(spec, "]:")
+		if end < 0 {
+			return nil, fmt.Errorf("invalid port spec %q", s)
+		}
+		hostIP, spec = spec[1:end], spec[end+2:]
+		if !strings.Contains(spec, ":") {
+			return nil, fmt.Errorf("invalid port spec %q: host ip requires a published port", s)
+		}
+	}
+
+	var published, target string
+	parts := strings.Split(spec, ":")
+	switch len(parts) {
+	case 1:
+		target = parts[0]
+	case 2:
+		published, target = parts[0], parts[1]
+	case 3:
+		if hostIP != "" {
+			return nil, fmt.Errorf("invalid port spec %q", s)
+		}
+		hostIP, published, target = parts[0], parts[1], parts[2]
+	default:
+		return nil, fmt.Errorf("invalid port spec %q", s)
+	}
+
+	targetStart, targetEnd, err := parsePortRange(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port spec %q: %w", s, err)
+	}
+	publishedStart, publishedEnd := 0, 0
+	if published != "" {
+		publishedStart, publishedEnd, err = parsePortRange(published)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port spec %q: %w", s, err)
+		}
+		if publishedEnd-publishedStart != targetEnd-targetStart && targetStart != targetEnd {
+			return nil, fmt.Errorf("invalid port spec %q: published and target ranges differ in size", s)
+		}
+	}
+	if targetStart == targetEnd && publishedStart != publishedEnd {
+		binding := PortBinding{HostIP: hostIP, Published: publishedStart, PublishedEnd: publishedEnd, Target: targetStart, Protocol: protocol}
+		return []PortBinding{binding}, nil
+	}
+
+	bindings := make([]PortBinding, 0, targetEnd-targetStart+1)
+	for i := 0; i <= targetEnd-targetStart; i++ {
+		binding := PortBinding{
+			HostIP:   hostIP,
+			Target:   targetStart + i,
+			Protocol: protocol,
+		}
+		if publishedStart > 0 {
+			binding.Published = publishedStart + i
+		}
+		bindings = append(bindings, binding)
+	}
+	return bindings, nil
+}
+
+func parsePortRange(s string) (int, int, error) {
+	startStr, endStr, isRange := strings.Cut(s, "-")
+	start, err := parsePort(startStr)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !isRange {
+		return start, start, nil
+	}
+	end, err := parsePort(endStr)
+	if err != nil {
+		return 0, 0, err
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("invalid port range %s", s)
+	}
+	return start, end, nil
+}
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return port, nil
+}
+
+// FormatPortSpec renders bindings back into short syntax entries, collapsing
+// runs of consecutive ports into ranges.
+func FormatPortSpec(bindings []PortBinding) []string {
+	result := []string{}
+	for i := 0; i < len(bindings); {
+		j := i + 1
+		for j < len(bindings) && continuesPortRun(bindings[j-1], bindings[j]) {
+			j++
+		}
+		first, last := bindings[i], bindings[j-1]
+		spec := formatPortRange(first.Target, last.Target)
+		if first.PublishedEnd > 0 {
+			spec = formatPortRange(first.Published, first.PublishedEnd) + ":" + spec
+		} else if first.Published > 0 {
+			spec = formatPortRange(first.Published, last.Published) + ":" + spec
+		}
+		if first.HostIP != "" {
+			hostIP := first.HostIP
+			if strings.Contains(hostIP, ":") {
+				hostIP = "[" + hostIP + "]"
+			}
+			if first.Published == 0 {
+				spec = ":" + spec
+			}
+			spec = hostIP + ":" + spec
+		}
+		if first.Protocol != "" && first.Protocol != "tcp" {
+			spec += "/" + first.Protocol
+		}
+		result = append(result, spec)
+		i = j
+	}
+	return result
+}
+
+func continuesPortRun(prev, next PortBinding) bool {
+	if prev.HostIP != next.HostIP || prev.Protocol != next.Protocol || next.Target != prev.Target+1 {
+		return false
+	}
+	if prev.PublishedEnd > 0 || next.PublishedEnd > 0 {
+		return false
+	}
+	if prev.Published == 0 || next.Published == 0 {
+		return prev.Published == next.Published
+	}
+	return next.Published == prev.Published+1
+}
+
+func formatPortRange(start, end int) string {
+	if start == end {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d-%d", start, end)
+}
+
+func (serviceConf *ComposeServiceConfig) ParsedPorts() ([]PortBinding, error) {
+	bindings := []PortBinding{}
+	for _, port := range serviceConf.Ports {
+		parsed, err := port.Bindings()
+		if err != nil {
+			return nil, err
+		}
+		bindings = append(bindings, parsed...)
+	}
+	return bindings, nil
+}
+
+// Endpoint is one published port of a service, together with the network
+// aliases the service is reachable by.
+type Endpoint struct {
+	HostIP    string
+	Published int
+	Target    int
+	Protocol  string
+	Aliases   []string
+}
+
+// Endpoints returns the published ports of every service that has any. Port
+// entries that fail to parse are skipped, and so are ports whose host port
+// docker picks, either ephemeral or from a range, as it is not known here.
+func (conf *ComposeConfig) Endpoints() map[string][]Endpoint {
+	result := map[string][]Endpoint{}
+	for _, name := range conf.ServiceNames() {
+		serviceConf := (*conf.Services)[name]
+		aliases := map[string]bool{}
+		for _, network := range serviceConf.Networks {
+			for _, alias := range network.Aliases {
+				aliases[alias] = true
+			}
+		}
+		endpoints := []Endpoint{}
+		for _, port := range serviceConf.Ports {
+			bindings, err := port.Bindings()
+			if err != nil {
+				continue
+			}
+			for _, binding := range bindings {
+				if binding.Published == 0 || binding.PublishedEnd > 0 {
+					continue
+				}
+				endpoints = append(endpoints, Endpoint{
+					HostIP:    binding.HostIP,
+					Published: binding.Published,
+					Target:    binding.Target,
+					Protocol:  binding.Protocol,
+					Aliases:   sortedKeys(aliases),
+				})
+			}
+		}
+		if len(endpoints) > 0 {
+			result[name] = endpoints
+		}
+	}
+	return result
+}
+
+// ValidatePortBudget flags every service publishing more than max host
+// ports, counting each port of a range. Ports docker assigns an ephemeral
+// host port to count as well. Unlike Validate this is a policy check, and
+// port entries that fail to parse are reported too.
+func (conf *ComposeConfig) ValidatePortBudget(max int) []error {
+	errs := []error{}
+	for _, name := range conf.ServiceNames() {
+		bindings, err := (*conf.Services)[name].ParsedPorts()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("service %s: %w", name, err))
+			continue
+		}
+		if len(bindings) > max {
+			errs = append(errs, fmt.Errorf("service %s publishes %d ports, more than the %d allowed", name, len(bindings), max))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePortSpec(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []PortBinding
+	}{
+		{"80", []PortBinding{{Target: 80, Protocol: "tcp"}}},
+		{"8080:80", []PortBinding{{Published: 8080, Target: 80, Protocol: "tcp"}}},
+		{"3000-3001:4000-4001/udp", []PortBinding{
+			{Published: 3000, Target: 4000, Protocol: "udp"},
+			{Published: 3001, Target: 4001, Protocol: "udp"},
+		}},
+		{"8000-8010:80", []PortBinding{{Published: 8000, PublishedEnd: 8010, Target: 80, Protocol: "tcp"}}},
+		{"127.0.0.1:8000-8010:80/udp", []PortBinding{{HostIP: "127.0.0.1", Published: 8000, PublishedEnd: 8010, Target: 80, Protocol: "udp"}}},
+	}
+	for _, test := range tests {
+		bindings, err := ParsePortSpec(test.spec)
+		if err != nil {
+			t.Errorf("ParsePortSpec(%q): %v", test.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(bindings, test.want) {
+			t.Errorf("ParsePortSpec(%q) = %+v, want %+v", test.spec, bindings, test.want)
+		}
+		if spec := FormatPortSpec(bindings); len(spec) != 1 || spec[0] != test.spec {
+			t.Errorf("FormatPortSpec(ParsePortSpec(%q)) = %q", test.spec, spec)
+		}
+	}
+	for _, spec := range []string{"8000-8010:80-81", "8000:80-81", "8010-8000:80"} {
+		if _, err := ParsePortSpec(spec); err == nil {
+			t.Errorf("ParsePortSpec(%q) accepted it", spec)
+		}
+	}
+}
+
+func TestLongPortPublishedRange(t *testing.T) {
+	port := &ComposePortConfig{Target: 80, Published: "8000-8010"}
+	bindings, err := port.Bindings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PortBinding{{Published: 8000, PublishedEnd: 8010, Target: 80, Protocol: "tcp"}}
+	if !reflect.DeepEqual(bindings, want) {
+		t.Errorf("got %+v, want %+v", bindings, want)
+	}
+	if spec := (ComposeServicePortConfig{Long: port}).String(); spec != "8000-8010:80" {
+		t.Errorf("got %s, want 8000-8010:80", spec)
+	}
+}
+
+func TestEndpointsSkipPortsDockerPicks(t *testing.T) {
+	conf := mustParse(t, `services:
+  web:
+    image: nginx
+    ports: ["8080:80", "443", "9000-9010:9000"]
+  worker:
+    image: worker
+    ports: ["5000"]
+`)
+	endpoints := conf.Endpoints()
+	want := map[string][]Endpoint{
+		"web": {{Published: 8080, Target: 80, Protocol: "tcp", Aliases: []string{}}},
+	}
+	if !reflect.DeepEqual(endpoints, want) {
+		t.Errorf("got %+v, want %+v", endpoints, want)
+	}
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	defaultComposeFileNames  = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+	defaultOverrideFileNames = []string{"compose.override.yaml", "compose.override.yml", "docker-compose.override.yaml", "docker-compose.override.yml"}
+)
+
+type Warning struct {
+	Path    string
+	Message string
+}
+
+func (w Warning) String() string {
+	if w.Path == "" {
+		return w.Message
+	}
+	return fmt.Sprintf("%s: %s", w.Path, w.Message)
+}
+
+func findFiles(dir string, names []string) []string {
+	found := []string{}
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			found = append(found, path)
+		}
+	}
+	return found
+}
+
+// FindComposeFile returns the default compose file of dir, using docker's
+// precedence: compose.yaml, compose.yml, docker-compose.yaml, docker-compose.yml.
+func FindComposeFile(dir string) (string, error) {
+	found := findFiles(dir, defaultComposeFileNames)
+	if len(found) == 0 {
+		return "", fmt.Errorf("no compose file found in %s, tried %s", dir, strings.Join(defaultComposeFileNames, ", "))
+	}
+	return found[0], nil
+}
+
+// LoadProject loads the default compose file of dir merged with its override
+// file, if any. Like docker compose, finding more than one candidate is not an
+// error but is reported as a warning.
+func LoadProject(dir string) (*ComposeConfig, []Warning, error) {
+	warnings := []Warning{}
+	composeFiles := findFiles(dir, defaultComposeFileNames)
+	if len(composeFiles) == 0 {
+		_, err := FindComposeFile(dir)
+		return nil, nil, err
+	}
+	if len(composeFiles) > 1 {
+		warnings = append(warnings, multipleFilesWarning(composeFiles))
+	}
+	config, err := GetConfigFromComposeFile(composeFiles[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	overrideFiles := findFiles(dir, defaultOverrideFileNames)
+	if len(overrideFiles) > 1 {
+		warnings = append(warnings, multipleFilesWarning(overrideFiles))
+	}
+	if len(overrideFiles) > 0 {
+		override, err := GetConfigFromComposeFile(overrideFiles[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		config.Merge(override)
+	}
+	return config, warnings, nil
+}
+
+func multipleFilesWarning(files []string) Warning {
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, filepath.Base(file))
+	}
+	return Warning{
+		Path:    filepath.Dir(files[0]),
+		Message: fmt.Sprintf("found multiple config files with supported names: %s, using %s", strings.Join(names, ", "), names[0]),
+	}
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	RestartConditionNone      = "none"
+	RestartConditionOnFailure = "on-failure"
+	RestartConditionAny       = "any"
+)
+
+type RestartPolicy struct {
+	Condition   string
+	Delay       string
+	MaxAttempts *uint64
+	Window      string
+}
+
+// parseRestart maps the service level restart field onto a restart policy.
+func parseRestart(restart string) (RestartPolicy, error) {
+	switch {
+	case restart == "" || restart == "no":
+		return RestartPolicy{Condition: RestartConditionNone}, nil
+	case restart == "always" || restart == "unless-stopped":
+		return RestartPolicy{Condition: RestartConditionAny}, nil
+	case restart == "on-failure":
+		return RestartPolicy{Condition: RestartConditionOnFailure}, nil
+	case strings.HasPrefix(restart, "on-failure:"):
+		attempts, err := strconv.ParseUint(strings.TrimPrefix(restart, "on-failure:"), 10, 64)
+		if err != nil {
+			return RestartPolicy{}, fmt.Errorf("invalid restart: %s", restart)
+		}
+		return RestartPolicy{Condition: RestartConditionOnFailure, MaxAttempts: &attempts}, nil
+	default:
+		return RestartPolicy{}, fmt.Errorf("invalid restart: %s", restart)
+	}
+}
+
+// EffectiveRestartPolicy reconciles restart with deploy.restart_policy. The
+// deploy policy takes precedence, but it is an error for the two to disagree
+// on the condition or the number of attempts.
+func (serviceConf *ComposeServiceConfig) EffectiveRestartPolicy() (RestartPolicy, error) {
+	restart, err := parseRestart(serviceConf.Restart)
+	if err != nil {
+		return RestartPolicy{}, err
+	}
+	if serviceConf.Deploy == nil || serviceConf.Deploy.RestartPolicy == nil {
+		return restart, nil
+	}
+
+	deployPolicy := serviceConf.Deploy.RestartPolicy
+	policy := RestartPolicy{
+		Condition:   deployPolicy.Condition,
+		Delay:       deployPolicy.Delay,
+		MaxAttempts: deployPolicy.MaxAttempts,
+		Window:      deployPolicy.Window,
+	}
+	switch policy.Condition {
+	case "":
+		policy.Condition = RestartConditionAny
+	case RestartConditionNone, RestartConditionOnFailure, RestartConditionAny:
+	default:
+		return RestartPolicy{}, fmt.Errorf("invalid deploy.restart_policy.condition: %s", policy.Condition)
+	}
+	if serviceConf.Restart == "" {
+		return policy, nil
+	}
+
+	if restart.Condition != policy.Condition {
+		return RestartPolicy{}, fmt.Errorf("restart %q conflicts with deploy.restart_policy.condition %q", serviceConf.Restart, policy.Condition)
+	}
+	if restart.MaxAttempts != nil {
+		if policy.MaxAttempts != nil && *policy.MaxAttempts != *restart.MaxAttempts {
+			return RestartPolicy{}, fmt.Errorf("restart %q conflicts with deploy.restart_policy.max_attempts %d", serviceConf.Restart, *policy.MaxAttempts)
+		}
+		policy.MaxAttempts = restart.MaxAttempts
+	}
+	return policy, nil
+}
@@ -0,0 +1,358 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// RuntimeContainerName returns the name compose gives the first container of the
+// service: container_name when set, <project>-<service>-1 otherwise.
+func (serviceConf *ComposeServiceConfig) RuntimeContainerName(projectName string) string {
+	if serviceConf.ContainerName != "" {
+		return serviceConf.ContainerName
+	}
+	return fmt.Sprintf("%s-%s-1", projectName, serviceConf.ServiceName)
+}
+
+// NetworkName returns the engine-level name of the network declared as name:
+// its name or external name when set, <project>_<name> otherwise.
+func (conf *ComposeConfig) NetworkName(projectName, name string) string {
+	if networkConf := conf.Networks[name]; networkConf != nil {
+		if networkConf.IsExternal() {
+			if externalName := networkConf.ExternalName(); externalName != "" {
+				return externalName
+			}
+			return name
+		}
+		if networkConf.Name != "" {
+			return networkConf.Name
+		}
+	}
+	return projectName + "_" + name
+}
+
+// VolumeName returns the engine-level name of the volume declared as name,
+// following the same rules as NetworkName.
+func (conf *ComposeConfig) VolumeName(projectName, name string) string {
+	if volumeConf := conf.Volumes[name]; volumeConf != nil {
+		if volumeConf.IsExternal() {
+			if externalName := volumeConf.ExternalName(); externalName != "" {
+				return externalName
+			}
+			return name
+		}
+		if volumeConf.Name != "" {
+			return volumeConf.Name
+		}
+	}
+	return projectName + "_" + name
+}
+
+// ToDockerRunArgs translates a service into the arguments of a detached
+// docker run (starting with "run"), naming resources the way compose does for
+// projectName. Settings docker run cannot express are returned as
+// untranslated, one human-readable note each; that includes every service
+// key the package does not model, except x- extensions. Multiple networks use
+// the --network name=... syntax of docker 25 and later.
+func (conf *ComposeConfig) ToDockerRunArgs(projectName, serviceName string) (args []string, untranslated []string, err error) {
+	args, command, untranslated, err := conf.dockerRunArgs(projectName, serviceName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(args, command...), untranslated, nil
+}
+
+// dockerRunArgs is ToDockerRunArgs with the arguments after the image, the
+// command, returned apart, so that callers can lay them out.
+func (conf *ComposeConfig) dockerRunArgs(projectName, serviceName string) (args, command, untranslated []string, err error) {
+	serviceConf, err := conf.LookupService(serviceName)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if serviceConf.Image == "" {
+		return nil, nil, nil, fmt.Errorf("service %s: docker run needs an image", serviceName)
+	}
+	untranslated = []string{}
+	args = []string{"run", "-d", "--name", serviceConf.RuntimeContainerName(projectName)}
+	flag := func(name, value string) {
+		if value != "" {
+			args = append(args, name, value)
+		}
+	}
+
+	flag("--hostname", serviceConf.Hostname)
+	flag("--domainname", serviceConf.DomainName)
+	flag("--mac-address", serviceConf.MacAddress)
+	flag("--restart", serviceConf.Restart)
+	args = append(args,
+		"--label", "com.docker.compose.project="+projectName,
+		"--label", "com.docker.compose.service="+serviceName,
+	)
+	if serviceConf.Labels != nil {
+		for _, key := range sortedKeys(*serviceConf.Labels) {
+			args = append(args, "--label", key+"="+(*serviceConf.Labels)[key])
+		}
+	}
+	if serviceConf.Annotations != nil {
+		for _, key := range serviceConf.Annotations.Keys() {
+			args = append(args, "--annotation", key+"="+serviceConf.Annotations.Values[key])
+		}
+	}
+	for _, envFile := range serviceConf.EnvFile {
+		if !envFile.IsRequired() {
+			untranslated = append(untranslated, "env_file "+envFile.Path+": docker run requires every env file to exist")
+			continue
+		}
+		args = append(args, "--env-file", envFile.Path)
+	}
+	if serviceConf.Environment != nil {
+		for _, key := range serviceConf.Environment.Keys() {
+			if serviceConf.Environment.IsNull(key) {
+				// passed through from the environment of docker run
+				args = append(args, "-e", key)
+				continue
+			}
+			args = append(args, "-e", key+"="+serviceConf.Environment.Values[key])
+		}
+	}
+	if serviceConf.Logging != nil {
+		flag("--log-driver", serviceConf.Logging.Driver)
+		for _, key := range sortedKeys(serviceConf.Logging.Options) {
+			args = append(args, "--log-opt", key+"="+serviceConf.Logging.Options[key])
+		}
+	}
+
+	switch mode := serviceConf.NetworkMode; {
+	case strings.HasPrefix(mode, "service:"):
+		dep, _ := conf.LookupService(strings.TrimPrefix(mode, "service:"))
+		if dep == nil {
+			untranslated = append(untranslated, "network_mode: "+mode)
+		} else {
+			args = append(args, "--network", "container:"+dep.RuntimeContainerName(projectName))
+		}
+	case mode != "":
+		args = append(args, "--network", mode)
+	case len(serviceConf.Networks) == 0:
+		args = append(args, "--network", "name="+conf.NetworkName(projectName, "default")+",alias="+serviceName)
+	default:
+		for _, network := range serviceConf.Networks {
+			spec := "name=" + conf.NetworkName(projectName, network.Name) + ",alias=" + serviceName
+			for _, alias := range network.Aliases {
+				spec += ",alias=" + alias
+			}
+			if network.Ipv4Address != "" {
+				spec += ",ip=" + network.Ipv4Address
+			}
+			if network.Ipv6Address != "" {
+				spec += ",ip6=" + network.Ipv6Address
+			}
+			args = append(args, "--network", spec)
+		}
+	}
+	for _, port := range serviceConf.Ports {
+		args = append(args, "-p", port.String())
+	}
+	for _, volume := range serviceConf.Volumes {
+		mount, err := volume.Mount()
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("service %s: %w", serviceName, err)
+		}
+		switch {
+		case mount.Type == VolumeTypeVolume && mount.Source != "":
+			mount.Source = conf.VolumeName(projectName, mount.Source)
+		case mount.Type == VolumeTypeBind && strings.HasPrefix(mount.Source, "."):
+			untranslated = append(untranslated, "volume "+volume.String()+": relative bind mount, normalize with a working directory first")
+			continue
+		}
+		if spec, ok := mount.ShortSyntax(); ok {
+			args = append(args, "-v", spec)
+		} else {
+			args = append(args, "--mount", mount.mountFlag())
+		}
+	}
+	for _, secret := range serviceConf.Secrets {
+		secretConf := conf.Secrets[secret.Source]
+		if secretConf == nil || secretConf.File == "" || !filepath.IsAbs(secretConf.File) {
+			untranslated = append(untranslated, "secret "+secret.Source+": only absolute file secrets can be mounted")
+			continue
+		}
+		target := secret.Target
+		if target == "" {
+			target = secret.Source
+		}
+		if !strings.HasPrefix(target, "/") {
+			target = "/run/secrets/" + target
+		}
+		args = append(args, "-v", secretConf.File+":"+target+":ro")
+	}
+
+	if serviceConf.IsPrivileged() {
+		args = append(args, "--privileged")
+	}
+	if serviceConf.IsReadOnly() {
+		args = append(args, "--read-only")
+	}
+	for _, opt := range serviceConf.SecurityOpt {
+		args = append(args, "--security-opt", opt)
+	}
+	for _, capability := range serviceConf.CapAdd {
+		args = append(args, "--cap-add", capability)
+	}
+	for _, capability := range serviceConf.CapDrop {
+		args = append(args, "--cap-drop", capability)
+	}
+	if target, ok := serviceConf.PidTargetService(); ok {
+		if dep, _ := conf.LookupService(target); dep != nil {
+			args = append(args, "--pid", "container:"+dep.RuntimeContainerName(projectName))
+		} else {
+			untranslated = append(untranslated, "pid: "+serviceConf.Pid)
+		}
+	} else {
+		flag("--pid", serviceConf.Pid)
+	}
+	flag("--cgroup-parent", serviceConf.CgroupParent)
+	flag("--ipc", serviceConf.Ipc)
+	flag("--isolation", serviceConf.Isolation)
+	flag("--userns", serviceConf.UsernsMode)
+	flag("--runtime", serviceConf.Runtime)
+	flag("--shm-size", serviceConf.ShmSize)
+	flag("--memory", serviceConf.MemLimit)
+	flag("--memory-reservation", serviceConf.MemReservation)
+	if serviceConf.CPUCount != nil {
+		args = append(args, "--cpu-count", fmt.Sprint(*serviceConf.CPUCount))
+	}
+	if serviceConf.CPUPercent != nil {
+		args = append(args, "--cpu-percent", fmt.Sprint(*serviceConf.CPUPercent))
+	}
+	if serviceConf.OOMKillDisable != nil && *serviceConf.OOMKillDisable {
+		args = append(args, "--oom-kill-disable")
+	}
+	if serviceConf.OOMScoreAdj != nil {
+		args = append(args, "--oom-score-adj", fmt.Sprint(*serviceConf.OOMScoreAdj))
+	}
+	for _, key := range sortedKeys(serviceConf.StorageOpt) {
+		args = append(args, "--storage-opt", key+"="+serviceConf.StorageOpt[key])
+	}
+	if blkio := serviceConf.BlkioConfig; blkio != nil {
+		if blkio.Weight != 0 {
+			args = append(args, "--blkio-weight", fmt.Sprint(blkio.Weight))
+		}
+		for _, device := range blkio.WeightDevice {
+			args = append(args, "--blkio-weight-device", fmt.Sprintf("%s:%d", device.Path, device.Weight))
+		}
+		for _, section := range []struct {
+			flag    string
+			devices []*ComposeBlkioThrottleDeviceConfig
+		}{
+			{"--device-read-bps", blkio.DeviceReadBps},
+			{"--device-write-bps", blkio.DeviceWriteBps},
+			{"--device-read-iops", blkio.DeviceReadIOps},
+			{"--device-write-iops", blkio.DeviceWriteIOps},
+		} {
+			for _, device := range section.devices {
+				args = append(args, section.flag, device.Path+":"+string(device.Rate))
+			}
+		}
+	}
+	switch policy, _ := serviceConf.ParsePullPolicy(); policy {
+	case PullPolicyAlways, PullPolicyNever:
+		args = append(args, "--pull", string(policy))
+	case PullPolicyBuild:
+		untranslated = append(untranslated, "pull_policy: build")
+	}
+
+	if healthcheck := serviceConf.Healthcheck; healthcheck != nil {
+		test := healthcheck.Test
+		switch {
+		case healthcheck.IsDisabled():
+			args = append(args, "--no-healthcheck")
+		case len(test) > 1 && test[0] == "CMD-SHELL":
+			flag("--health-cmd", strings.Join(test[1:], " "))
+		case len(test) > 1 && test[0] == "CMD":
+			quoted := make([]string, 0, len(test)-1)
+			for _, arg := range test[1:] {
+				quoted = append(quoted, shellQuote(arg))
+			}
+			flag("--health-cmd", strings.Join(quoted, " "))
+		case len(test) == 1:
+			flag("--health-cmd", test[0])
+		}
+		flag("--health-interval", healthcheck.Interval)
+		flag("--health-timeout", healthcheck.Timeout)
+		flag("--health-start-period", healthcheck.StartPeriod)
+		if healthcheck.Retries != nil {
+			args = append(args, "--health-retries", fmt.Sprint(*healthcheck.Retries))
+		}
+	}
+
+	if deploy := serviceConf.Deploy; deploy != nil {
+		if deploy.Replicas != nil && *deploy.Replicas != 1 {
+			untranslated = append(untranslated, fmt.Sprintf("deploy.replicas: %d", *deploy.Replicas))
+		}
+		if resources := deploy.Resources; resources != nil {
+			if resources.Limits != nil {
+				flag("--cpus", resources.Limits.Cpus)
+				if serviceConf.MemLimit == "" {
+					flag("--memory", resources.Limits.Memory)
+				}
+			}
+			if resources.Reservations != nil {
+				if serviceConf.MemReservation == "" {
+					flag("--memory-reservation", resources.Reservations.Memory)
+				}
+				for _, device := range resources.Reservations.Devices {
+					if !containsString(device.Capabilities, "gpu") {
+						untranslated = append(untranslated, "deploy.resources.reservations.devices: non-gpu device request")
+						continue
+					}
+					gpus := string(device.Count)
+					if len(device.DeviceIDs) > 0 {
+						gpus = `"device=` + strings.Join(device.DeviceIDs, ",") + `"`
+					} else if gpus == "" {
+						gpus = "all"
+					}
+					args = append(args, "--gpus", gpus)
+				}
+			}
+		}
+	}
+	if serviceConf.Scale != nil && *serviceConf.Scale != 1 {
+		untranslated = append(untranslated, fmt.Sprintf("scale: %d", *serviceConf.Scale))
+	}
+	if serviceConf.Build != nil {
+		untranslated = append(untranslated, "build: the image must already exist")
+	}
+
+	for _, key := range sortedKeys(serviceConf.RawExtra) {
+		if !strings.HasPrefix(key, "x-") {
+			untranslated = append(untranslated, key+": not supported by this package")
+		}
+	}
+
+	// --entrypoint takes a single word, the rest of the entrypoint runs with
+	// the command as its arguments
+	if serviceConf.Entrypoint != nil {
+		entrypoint := ""
+		if len(serviceConf.Entrypoint) > 0 {
+			entrypoint = serviceConf.Entrypoint[0]
+			command = append(command, serviceConf.Entrypoint[1:]...)
+		}
+		args = append(args, "--entrypoint", entrypoint)
+	}
+	flag("--user", serviceConf.User)
+	flag("--workdir", serviceConf.WorkingDir)
+	command = append(command, serviceConf.Command...)
+
+	args = append(args, serviceConf.Image)
+	return args, command, untranslated, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
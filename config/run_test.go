@@ -0,0 +1,87 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const commandCompose = `services:
+  web:
+    image: nginx
+    command: ["echo", "hi"]
+    entrypoint: /bin/sh -c
+    user: "1000"
+    working_dir: /app
+    tty: true
+    x-team: web
+`
+
+func TestToDockerRunArgsTranslatesTheProcess(t *testing.T) {
+	conf := mustParse(t, commandCompose)
+	args, untranslated, err := conf.ToDockerRunArgs("p", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	image := len(args) - 4
+	if image < 0 || args[image] != "nginx" {
+		t.Fatalf("got %q, want the image followed by the command", args)
+	}
+	if tail := args[image+1:]; !reflect.DeepEqual(tail, []string{"-c", "echo", "hi"}) {
+		t.Errorf("got %q after the image, want the rest of the entrypoint and the command", tail)
+	}
+	options := strings.Join(args[:image], " ")
+	for _, want := range []string{"--entrypoint /bin/sh", "--user 1000", "--workdir /app"} {
+		if !strings.Contains(options, want) {
+			t.Errorf("%s is missing before the image in %q", want, args)
+		}
+	}
+	if !reflect.DeepEqual(untranslated, []string{"tty: not supported by this package"}) {
+		t.Errorf("got untranslated %q, want tty alone", untranslated)
+	}
+}
+
+func TestToDockerRunArgsClearsAnEmptyEntrypoint(t *testing.T) {
+	conf := mustParse(t, "services:\n  web:\n    image: nginx\n    entrypoint: []\n")
+	args, _, err := conf.ToDockerRunArgs("p", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"--entrypoint", "", "nginx"}; !reflect.DeepEqual(args[len(args)-3:], want) {
+		t.Errorf("got %q, want it to end with %q", args, want)
+	}
+}
+
+func TestToStartupScriptRunsTheCommand(t *testing.T) {
+	conf := mustParse(t, commandCompose)
+	script, err := conf.ToStartupScript("p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"# not translated: tty: not supported by this package\n", "\\\n  nginx -c echo hi\n", "\\\n  --entrypoint /bin/sh \\\n"} {
+		if !strings.Contains(string(script), want) {
+			t.Errorf("%q is missing from:\n%s", want, script)
+		}
+	}
+}
+
+func TestSplitShellWords(t *testing.T) {
+	tests := map[string][]string{
+		"":                       {},
+		"echo hi":                {"echo", "hi"},
+		`sh -c 'echo "$A" b'`:    {"sh", "-c", `echo "$A" b`},
+		`echo "a \"b\" \n" c\ d`: {"echo", `a "b" \n`, "c d"},
+		`a''b ""`:                {"ab", ""},
+	}
+	for input, want := range tests {
+		words, err := splitShellWords(input)
+		if err != nil || !reflect.DeepEqual(words, want) {
+			t.Errorf("splitShellWords(%q) = %q, %v, want %q", input, words, err, want)
+		}
+	}
+	for _, input := range []string{`echo 'a`, `echo "a`} {
+		if _, err := splitShellWords(input); err == nil {
+			t.Errorf("splitShellWords(%q) accepted it", input)
+		}
+	}
+}
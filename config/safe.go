@@ -0,0 +1,131 @@
+package config
+
+import (
+	"gopkg.in/yaml.v3"
+	"sync"
+)
+
+// Clone returns a deep copy of the config.
+func (conf *ComposeConfig) Clone() (*ComposeConfig, error) {
+	node := &yaml.Node{}
+	if err := node.Encode(conf); err != nil {
+		return nil, err
+	}
+	clone := &ComposeConfig{}
+	if err := node.Decode(clone); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+// Clone returns a deep copy of the service, keeping its ServiceName.
+func (serviceConf *ComposeServiceConfig) Clone() (*ComposeServiceConfig, error) {
+	node := &yaml.Node{}
+	if err := node.Encode(serviceConf); err != nil {
+		return nil, err
+	}
+	clone := &ComposeServiceConfig{}
+	if err := node.Decode(clone); err != nil {
+		return nil, err
+	}
+	clone.ServiceName = serviceConf.ServiceName
+	return clone, nil
+}
+
+// SafeConfig guards a ComposeConfig with a read-write mutex so it can be
+// shared between goroutines. ComposeConfig itself is not safe for concurrent
+// use: once wrapped, the config must only be reached through the SafeConfig,
+// which never hands out pointers into it.
+type SafeConfig struct {
+	mu   sync.RWMutex
+	conf *ComposeConfig
+}
+
+func NewSafeConfig(conf *ComposeConfig) *SafeConfig {
+	return &SafeConfig{conf: conf}
+}
+
+// GetService returns a copy of the service called name.
+func (s *SafeConfig) GetService(name string) (*ComposeServiceConfig, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	serviceConf, err := s.conf.LookupService(name)
+	if err != nil {
+		return nil, err
+	}
+	return serviceConf.Clone()
+}
+
+func (s *SafeConfig) ServiceNames() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.conf.ServiceNames()
+}
+
+// UpdateService calls fn with a copy of the service called name and stores
+// the copy once fn returns nil, so a failing fn leaves the config untouched.
+func (s *SafeConfig) UpdateService(name string, fn func(serviceConf *ComposeServiceConfig) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	serviceConf, err := s.conf.LookupService(name)
+	if err != nil {
+		return err
+	}
+	clone, err := serviceConf.Clone()
+	if err != nil {
+		return err
+	}
+	if err = fn(clone); err != nil {
+		return err
+	}
+	clone.ServiceName = name
+	s.conf.SetService(name, clone)
+	return nil
+}
+
+// Update calls fn with a copy of the whole config and replaces the config with
+// it once fn returns nil.
+func (s *SafeConfig) Update(fn func(conf *ComposeConfig) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clone, err := s.conf.Clone()
+	if err != nil {
+		return err
+	}
+	if err = fn(clone); err != nil {
+		return err
+	}
+	s.conf = clone
+	return nil
+}
+
+// Snapshot returns a copy of the config that the caller owns.
+func (s *SafeConfig) Snapshot() (*ComposeConfig, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.conf.Clone()
+}
+
+func (s *SafeConfig) ExportYAML() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.conf.ExportYAML()
+}
+
+func (s *SafeConfig) ExportYAMLWithOptions(opts YAMLOptions) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.conf.ExportYAMLWithOptions(opts)
+}
+
+func (s *SafeConfig) ExportJSON() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.conf.ExportJSON()
+}
+
+func (s *SafeConfig) WriteToFile(path string, opts ...WriteOption) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.conf.WriteToFile(path, opts...)
+}
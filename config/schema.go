@@ -0,0 +1,133 @@
+package config
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"gopkg.in/yaml.v3"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//go:embed schema/compose-spec.json
+var composeSpecSchema []byte
+
+var (
+	composeSchemaOnce sync.Once
+	composeSchema     *jsonschema.Schema
+	composeSchemaErr  error
+)
+
+type SchemaError struct {
+	Path    string
+	Message string
+}
+
+func (e SchemaError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+func (conf *ComposeConfig) ValidateSchema() []SchemaError {
+	content, err := conf.ExportYAML()
+	if err != nil {
+		return []SchemaError{{Message: err.Error()}}
+	}
+	errs, err := validateSchema(content)
+	if err != nil {
+		return []SchemaError{{Message: err.Error()}}
+	}
+	return errs
+}
+
+func ValidateFileSchema(path string) ([]SchemaError, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return validateSchema(content)
+}
+
+func loadComposeSchema() (*jsonschema.Schema, error) {
+	composeSchemaOnce.Do(func() {
+		compiler := jsonschema.NewCompiler()
+		if err := compiler.AddResource("compose_spec.json", bytes.NewReader(composeSpecSchema)); err != nil {
+			composeSchemaErr = err
+			return
+		}
+		composeSchema, composeSchemaErr = compiler.Compile("compose_spec.json")
+	})
+	return composeSchema, composeSchemaErr
+}
+
+// validateSchema accepts YAML or JSON content, JSON being a subset of YAML.
+func validateSchema(content []byte) ([]SchemaError, error) {
+	schema, err := loadComposeSchema()
+	if err != nil {
+		return nil, err
+	}
+
+	var raw any
+	if err = yaml.Unmarshal(content, &raw); err != nil {
+		return nil, err
+	}
+	// round-trip through JSON so the document only holds JSON-compatible types
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	if err = json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	err = schema.Validate(doc)
+	if err == nil {
+		return nil, nil
+	}
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return nil, err
+	}
+	result := make([]SchemaError, 0)
+	collectSchemaErrors(validationErr, &result)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+	return result, nil
+}
+
+func collectSchemaErrors(validationErr *jsonschema.ValidationError, result *[]SchemaError) {
+	if len(validationErr.Causes) == 0 {
+		*result = append(*result, SchemaError{
+			Path:    schemaPointerToPath(validationErr.InstanceLocation),
+			Message: validationErr.Message,
+		})
+		return
+	}
+	for _, cause := range validationErr.Causes {
+		collectSchemaErrors(cause, result)
+	}
+}
+
+// schemaPointerToPath turns a JSON pointer such as /services/web/ports/0
+// into services.web.ports.0
+func schemaPointerToPath(pointer string) string {
+	pointer = strings.TrimPrefix(pointer, "/")
+	if pointer == "" {
+		return ""
+	}
+	parts := strings.Split(pointer, "/")
+	for i, part := range parts {
+		part = strings.ReplaceAll(part, "~1", "/")
+		parts[i] = strings.ReplaceAll(part, "~0", "~")
+	}
+	return strings.Join(parts, ".")
+}
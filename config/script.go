@@ -0,0 +1,214 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const startupScriptHelpers = `wait_healthy() {
+  local container=$1 i
+  for i in $(seq 1 120); do
+    if [ "$(docker inspect -f '{{if .State.Health}}{{.State.Health.Status}}{{end}}' "$container" 2>/dev/null)" = healthy ]; then
+      return 0
+    fi
+    sleep 1
+  done
+  echo "timed out waiting for $container to become healthy" >&2
+  return 1
+}
+
+wait_completed() {
+  local container=$1
+  if [ "$(docker wait "$container")" != 0 ]; then
+    echo "$container did not complete successfully" >&2
+    return 1
+  fi
+}
+`
+
+// ToStartupScript renders a bash script that creates the project networks and
+// volumes and starts every service with docker run in dependency order,
+// waiting on service_healthy and service_completed_successfully dependencies.
+// Settings without a docker run equivalent are left as comments.
+func (conf *ComposeConfig) ToStartupScript(projectName string) ([]byte, error) {
+	order, err := conf.DependencyOrder()
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "#!/usr/bin/env bash\n# Starts the %s compose project without docker compose.\nset -euo pipefail\n\n", projectName)
+	b.WriteString(startupScriptHelpers)
+
+	networks := map[string]bool{}
+	for name := range conf.Networks {
+		networks[name] = true
+	}
+	volumes := map[string]bool{}
+	for name := range conf.Volumes {
+		volumes[name] = true
+	}
+	for _, name := range order {
+		serviceNetworks, serviceVolumes := (*conf.Services)[name].usedResources()
+		for _, network := range serviceNetworks {
+			networks[network] = true
+		}
+		for _, volume := range serviceVolumes {
+			volumes[volume] = true
+		}
+	}
+
+	if len(networks) > 0 {
+		b.WriteString("\n")
+	}
+	for _, name := range sortedKeys(networks) {
+		engineName := conf.NetworkName(projectName, name)
+		args := conf.networkCreateArgs(projectName, name)
+		if args == nil {
+			fmt.Fprintf(&b, "# network %s is external and must already exist\n", engineName)
+			continue
+		}
+		fmt.Fprintf(&b, "docker network inspect %s >/dev/null 2>&1 || docker %s\n", shellQuote(engineName), shellJoin(args))
+	}
+
+	if len(volumes) > 0 {
+		b.WriteString("\n")
+	}
+	for _, name := range sortedKeys(volumes) {
+		engineName := conf.VolumeName(projectName, name)
+		args := conf.volumeCreateArgs(projectName, name)
+		if args == nil {
+			fmt.Fprintf(&b, "# volume %s is external and must already exist\n", engineName)
+			continue
+		}
+		fmt.Fprintf(&b, "docker volume inspect %s >/dev/null 2>&1 || docker %s\n", shellQuote(engineName), shellJoin(args))
+	}
+
+	for _, name := range order {
+		serviceConf := (*conf.Services)[name]
+		args, command, untranslated, err := conf.dockerRunArgs(projectName, name)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&b, "\n# service %s\n", name)
+		if serviceConf.DependsOn != nil {
+			for _, dep := range sortedKeys(*serviceConf.DependsOn) {
+				depConf := (*conf.Services)[dep]
+				switch (*serviceConf.DependsOn)[dep].Condition {
+				case "service_healthy":
+					fmt.Fprintf(&b, "wait_healthy %s\n", shellQuote(depConf.RuntimeContainerName(projectName)))
+				case "service_completed_successfully":
+					fmt.Fprintf(&b, "wait_completed %s\n", shellQuote(depConf.RuntimeContainerName(projectName)))
+				}
+			}
+		}
+		for _, note := range untranslated {
+			fmt.Fprintf(&b, "# not translated: %s\n", note)
+		}
+		b.WriteString("docker")
+		for i, arg := range args {
+			if i > 1 && (strings.HasPrefix(arg, "-") || i == len(args)-1) {
+				b.WriteString(" \\\n ")
+			}
+			b.WriteString(" " + shellQuote(arg))
+		}
+		for _, arg := range command {
+			b.WriteString(" " + shellQuote(arg))
+		}
+		b.WriteString("\n")
+	}
+	return b.Bytes(), nil
+}
+
+// usedResources returns the networks the service attaches to, including the
+// implicit default one, and the named volumes it mounts.
+func (serviceConf *ComposeServiceConfig) usedResources() (networks, volumes []string) {
+	networks = serviceConf.Networks.Names()
+	if serviceConf.NetworkMode == "" && len(networks) == 0 {
+		networks = []string{"default"}
+	}
+	for _, volume := range serviceConf.Volumes {
+		if mount, err := volume.Mount(); err == nil && mount.Type == VolumeTypeVolume && mount.Source != "" {
+			volumes = append(volumes, mount.Source)
+		}
+	}
+	return networks, volumes
+}
+
+// networkCreateArgs returns the docker arguments creating the network, or nil
+// for an external one.
+func (conf *ComposeConfig) networkCreateArgs(projectName, name string) []string {
+	networkConf := conf.Networks[name]
+	if networkConf != nil && networkConf.IsExternal() {
+		return nil
+	}
+	args := []string{"network", "create", "--label", "com.docker.compose.project=" + projectName}
+	if networkConf != nil {
+		if networkConf.Driver != "" {
+			args = append(args, "--driver", networkConf.Driver)
+		}
+		for _, key := range sortedKeys(networkConf.DriverOpts) {
+			args = append(args, "--opt", key+"="+networkConf.DriverOpts[key])
+		}
+		if ipam := networkConf.Ipam; ipam != nil {
+			if ipam.Driver != "" {
+				args = append(args, "--ipam-driver", ipam.Driver)
+			}
+			for _, pool := range ipam.Config {
+				if pool.Subnet != "" {
+					args = append(args, "--subnet", pool.Subnet)
+				}
+				if pool.IPRange != "" {
+					args = append(args, "--ip-range", pool.IPRange)
+				}
+				if pool.Gateway != "" {
+					args = append(args, "--gateway", pool.Gateway)
+				}
+				for _, key := range sortedKeys(pool.AuxAddresses) {
+					args = append(args, "--aux-address", key+"="+pool.AuxAddresses[key])
+				}
+			}
+			for _, key := range sortedKeys(ipam.Options) {
+				args = append(args, "--ipam-opt", key+"="+ipam.Options[key])
+			}
+		}
+		if networkConf.Labels != nil {
+			for _, key := range sortedKeys(*networkConf.Labels) {
+				args = append(args, "--label", key+"="+(*networkConf.Labels)[key])
+			}
+		}
+	}
+	return append(args, conf.NetworkName(projectName, name))
+}
+
+// volumeCreateArgs returns the docker arguments creating the volume, or nil
+// for an external one.
+func (conf *ComposeConfig) volumeCreateArgs(projectName, name string) []string {
+	volumeConf := conf.Volumes[name]
+	if volumeConf != nil && volumeConf.IsExternal() {
+		return nil
+	}
+	args := []string{"volume", "create", "--label", "com.docker.compose.project=" + projectName}
+	if volumeConf != nil {
+		if volumeConf.Driver != "" {
+			args = append(args, "--driver", volumeConf.Driver)
+		}
+		for _, key := range sortedKeys(volumeConf.DriverOpts) {
+			args = append(args, "--opt", key+"="+volumeConf.DriverOpts[key])
+		}
+		if volumeConf.Labels != nil {
+			for _, key := range sortedKeys(*volumeConf.Labels) {
+				args = append(args, "--label", key+"="+(*volumeConf.Labels)[key])
+			}
+		}
+	}
+	return append(args, conf.VolumeName(projectName, name))
+}
+
+func shellJoin(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		quoted = append(quoted, shellQuote(arg))
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+type Severity string
+
+const (
+	SeverityLow    Severity = "low"
+	SeverityMedium Severity = "medium"
+	SeverityHigh   Severity = "high"
+)
+
+// SecurityFinding is one result of SecurityAudit. RuleID is stable so CI can
+// allowlist individual findings.
+type SecurityFinding struct {
+	Service  string
+	Severity Severity
+	RuleID   string
+	Message  string
+}
+
+func (f SecurityFinding) String() string {
+	return fmt.Sprintf("[%s] %s: %s (%s)", f.Severity, f.Service, f.Message, f.RuleID)
+}
+
+var sensitiveHostPaths = map[string]Severity{
+	"/":                    SeverityHigh,
+	"/var/run/docker.sock": SeverityHigh,
+	"/run/docker.sock":     SeverityHigh,
+	"/etc":                 SeverityHigh,
+	"/proc":                SeverityHigh,
+	"/sys":                 SeverityMedium,
+	"/root":                SeverityMedium,
+}
+
+var dangerousCapabilities = map[string]Severity{
+	"ALL":        SeverityHigh,
+	"SYS_ADMIN":  SeverityHigh,
+	"SYS_MODULE": SeverityHigh,
+	"SYS_PTRACE": SeverityMedium,
+	"NET_ADMIN":  SeverityMedium,
+}
+
+// SecurityAudit reports common hardening gaps per service, sorted by service
+// name: privileged mode, host namespaces, sensitive bind mounts, a writable
+// root filesystem, dangerous capabilities and disabled seccomp or apparmor.
+func (conf *ComposeConfig) SecurityAudit() []SecurityFinding {
+	findings := []SecurityFinding{}
+	for _, name := range conf.ServiceNames() {
+		findings = append(findings, (*conf.Services)[name].securityAudit(name)...)
+	}
+	return findings
+}
+
+func (serviceConf *ComposeServiceConfig) securityAudit(name string) []SecurityFinding {
+	findings := []SecurityFinding{}
+	add := func(severity Severity, ruleID, format string, args ...any) {
+		findings = append(findings, SecurityFinding{
+			Service:  name,
+			Severity: severity,
+			RuleID:   ruleID,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	if serviceConf.IsPrivileged() {
+		add(SeverityHigh, "privileged", "runs in privileged mode")
+	}
+	if serviceConf.NetworkMode == "host" {
+		add(SeverityHigh, "host-network", "shares the host network namespace")
+	}
+	if serviceConf.Pid == "host" {
+		add(SeverityHigh, "host-pid", "shares the host pid namespace")
+	}
+	if serviceConf.Ipc == "host" {
+		add(SeverityMedium, "host-ipc", "shares the host ipc namespace")
+	}
+	for _, volume := range serviceConf.Volumes {
+		mount, err := volume.Mount()
+		if err != nil || mount.Type != VolumeTypeBind {
+			continue
+		}
+		if severity, ok := sensitiveHostPaths[filepath.Clean(mount.Source)]; ok {
+			add(severity, "sensitive-bind-mount", "mounts sensitive host path %s", mount.Source)
+		}
+	}
+	if !serviceConf.IsReadOnly() {
+		add(SeverityLow, "writable-rootfs", "root filesystem is not read_only")
+	}
+	for _, capability := range serviceConf.CapAdd {
+		normalized := strings.TrimPrefix(strings.ToUpper(capability), "CAP_")
+		if severity, ok := dangerousCapabilities[normalized]; ok {
+			add(severity, "dangerous-capability", "adds capability %s", normalized)
+		}
+	}
+	for _, opt := range serviceConf.SecurityOpt {
+		key, value, _ := strings.Cut(strings.Replace(opt, "=", ":", 1), ":")
+		if value != "unconfined" {
+			continue
+		}
+		switch key {
+		case "seccomp":
+			add(SeverityHigh, "seccomp-unconfined", "disables the seccomp profile")
+		case "apparmor":
+			add(SeverityHigh, "apparmor-unconfined", "disables the apparmor profile")
+		}
+	}
+	return findings
+}
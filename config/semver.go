@@ -0,0 +1,165 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var regSemver = regexp.MustCompile(`^(v?)(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?(?:\+([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?$`)
+
+type BumpLevel int
+
+const (
+	BumpPatch BumpLevel = iota
+	BumpMinor
+	BumpMajor
+)
+
+func (level BumpLevel) String() string {
+	switch level {
+	case BumpPatch:
+		return "patch"
+	case BumpMinor:
+		return "minor"
+	case BumpMajor:
+		return "major"
+	default:
+		return fmt.Sprintf("BumpLevel(%d)", int(level))
+	}
+}
+
+type semver struct {
+	prefix     string
+	major      uint64
+	minor      uint64
+	patch      uint64
+	prerelease []string
+}
+
+func parseSemver(version string) (*semver, error) {
+	match := regSemver.FindStringSubmatch(version)
+	if match == nil {
+		return nil, fmt.Errorf("version %q is not a semantic version", version)
+	}
+	v := &semver{prefix: match[1]}
+	for i, target := range []*uint64{&v.major, &v.minor, &v.patch} {
+		n, err := strconv.ParseUint(match[i+2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("version %q is not a semantic version: %w", version, err)
+		}
+		*target = n
+	}
+	if match[5] != "" {
+		v.prerelease = strings.Split(match[5], ".")
+	}
+	return v, nil
+}
+
+func (v *semver) String() string {
+	s := fmt.Sprintf("%s%d.%d.%d", v.prefix, v.major, v.minor, v.patch)
+	if len(v.prerelease) > 0 {
+		s += "-" + strings.Join(v.prerelease, ".")
+	}
+	return s
+}
+
+// compare orders versions by semver precedence; build metadata is ignored.
+func (v *semver) compare(other *semver) int {
+	for _, pair := range [][2]uint64{{v.major, other.major}, {v.minor, other.minor}, {v.patch, other.patch}} {
+		if pair[0] != pair[1] {
+			if pair[0] < pair[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+	// a release has higher precedence than any of its pre-releases
+	switch {
+	case len(v.prerelease) == 0 && len(other.prerelease) == 0:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(other.prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.prerelease) && i < len(other.prerelease); i++ {
+		if c := comparePrereleaseIdentifier(v.prerelease[i], other.prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(v.prerelease) < len(other.prerelease):
+		return -1
+	case len(v.prerelease) > len(other.prerelease):
+		return 1
+	}
+	return 0
+}
+
+func comparePrereleaseIdentifier(a, b string) int {
+	aNum, aErr := strconv.ParseUint(a, 10, 64)
+	bNum, bErr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aErr == nil && bErr == nil:
+		switch {
+		case aNum < bNum:
+			return -1
+		case aNum > bNum:
+			return 1
+		}
+		return 0
+	case aErr == nil:
+		// numeric identifiers sort before alphanumeric ones
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// CompareVersion compares the image tag of the service with other using
+// semver precedence, returning -1, 0 or 1. A leading "v" is accepted on
+// either side.
+func (serviceConf *ComposeServiceConfig) CompareVersion(other string) (int, error) {
+	current, err := parseSemver(serviceConf.GetVersion())
+	if err != nil {
+		return 0, err
+	}
+	target, err := parseSemver(other)
+	if err != nil {
+		return 0, err
+	}
+	return current.compare(target), nil
+}
+
+func (serviceConf *ComposeServiceConfig) IsNewerThan(tag string) (bool, error) {
+	c, err := serviceConf.CompareVersion(tag)
+	if err != nil {
+		return false, err
+	}
+	return c > 0, nil
+}
+
+// BumpVersion increments the given component of the image tag, resetting the
+// lower components and dropping any pre-release or build metadata.
+func (serviceConf *ComposeServiceConfig) BumpVersion(level BumpLevel) error {
+	v, err := parseSemver(serviceConf.GetVersion())
+	if err != nil {
+		return err
+	}
+	switch level {
+	case BumpMajor:
+		v.major, v.minor, v.patch = v.major+1, 0, 0
+	case BumpMinor:
+		v.minor, v.patch = v.minor+1, 0
+	case BumpPatch:
+		v.patch++
+	default:
+		return fmt.Errorf("unknown bump level %s", level)
+	}
+	v.prerelease = nil
+	serviceConf.SetVersion(v.String())
+	return nil
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+func (conf *ComposeConfig) SplitByService() map[string]*ComposeConfig {
+	result := map[string]*ComposeConfig{}
+	if conf.Services == nil {
+		return result
+	}
+	for name, serviceConf := range *conf.Services {
+		services := ComposeServicesConfig{name: serviceConf}
+		split := &ComposeConfig{
+			Version:  conf.Version,
+			Services: &services,
+		}
+
+		networks := serviceConf.Networks.Names()
+		if len(networks) == 0 {
+			networks = []string{"default"}
+		}
+		for _, network := range networks {
+			if networkConf, ok := conf.Networks[network]; ok {
+				if split.Networks == nil {
+					split.Networks = map[string]*ComposeNetworkConfig{}
+				}
+				split.Networks[network] = networkConf
+			}
+		}
+
+		for _, volume := range serviceConf.Volumes {
+			mount, err := volume.Mount()
+			if err != nil || mount.Type != VolumeTypeVolume || mount.Source == "" {
+				continue
+			}
+			if volumeConf, ok := conf.Volumes[mount.Source]; ok {
+				if split.Volumes == nil {
+					split.Volumes = map[string]*ComposeVolumeConfig{}
+				}
+				split.Volumes[mount.Source] = volumeConf
+			}
+		}
+
+		for _, secret := range serviceConf.Secrets {
+			if secretConf, ok := conf.Secrets[secret.Source]; ok {
+				if split.Secrets == nil {
+					split.Secrets = map[string]*ComposeSecretConfig{}
+				}
+				split.Secrets[secret.Source] = secretConf
+			}
+		}
+
+		result[name] = split
+	}
+	return result
+}
+
+func (conf *ComposeConfig) WriteSplit(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	splits := conf.SplitByService()
+	names := make([]string, 0, len(splits))
+	for name := range splits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		content, err := splits[name].ExportYAML()
+		if err != nil {
+			return err
+		}
+		if err = os.WriteFile(filepath.Join(dir, name+".yml"), content, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteServiceFiles writes the SplitByService files into dir like WriteSplit
+// and returns the path of every file by service. Each file must stand on its
+// own, so depends_on is dropped: the services it names live in other files.
+// Networks and volumes used by more than one service are declared external
+// in each file, since they are not any single service's to create, under the
+// name they get in projectName, such as <project>_<key>. Secrets are copied
+// as they are, as file and environment secrets are not created.
+//
+// It is not called SplitByService(dir) because that name already returns the
+// configs in memory. The project name is needed because an external network or
+// volume must be named the way the master file's project created it, which dir
+// alone cannot tell.
+func (conf *ComposeConfig) WriteServiceFiles(dir, projectName string) (map[string]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	splits := conf.SplitByService()
+	networkUsers, volumeUsers := map[string]int{}, map[string]int{}
+	for _, split := range splits {
+		for name := range split.Networks {
+			networkUsers[name]++
+		}
+		for name := range split.Volumes {
+			volumeUsers[name]++
+		}
+	}
+
+	paths := map[string]string{}
+	for _, name := range sortedKeys(splits) {
+		split := splits[name]
+		// the split shares its service with conf, change a copy
+		serviceConf := *(*split.Services)[name]
+		serviceConf.DependsOn = nil
+		(*split.Services)[name] = &serviceConf
+		for network, networkConf := range split.Networks {
+			if networkUsers[network] > 1 && (networkConf == nil || !networkConf.IsExternal()) {
+				split.Networks[network] = &ComposeNetworkConfig{
+					Name:     conf.NetworkName(projectName, network),
+					External: &ComposeExternalConfig{External: true},
+				}
+			}
+		}
+		for volume, volumeConf := range split.Volumes {
+			if volumeUsers[volume] > 1 && (volumeConf == nil || !volumeConf.IsExternal()) {
+				split.Volumes[volume] = &ComposeVolumeConfig{
+					Name:     conf.VolumeName(projectName, volume),
+					External: &ComposeExternalConfig{External: true},
+				}
+			}
+		}
+		content, err := split.ExportYAML()
+		if err != nil {
+			return nil, err
+		}
+		path := filepath.Join(dir, name+".yml")
+		if err = os.WriteFile(path, content, 0644); err != nil {
+			return nil, err
+		}
+		paths[name] = path
+	}
+	return paths, nil
+}
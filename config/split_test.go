@@ -0,0 +1,57 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestWriteServiceFilesStandAlone(t *testing.T) {
+	conf := mustParse(t, `services:
+  web:
+    image: nginx
+    depends_on: [db]
+    networks: [back]
+    volumes: ["data:/data"]
+  db:
+    image: postgres
+    networks: [back]
+    volumes: ["data:/var/lib/postgresql/data", "own:/own"]
+networks:
+  back: {driver: bridge}
+volumes:
+  data: {name: shared-data}
+  own: {}
+`)
+	paths, err := conf.WriteServiceFiles(t.TempDir(), "shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*conf.Services)["web"].DependsOn == nil {
+		t.Error("depends_on removed from the original config")
+	}
+
+	web, err := GetConfigFromComposeFile(paths["web"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*web.Services)["web"].DependsOn != nil {
+		t.Error("web.yml keeps depends_on on a service it does not define")
+	}
+	if _, err = web.DependencyOrder(); err != nil {
+		t.Errorf("web.yml does not stand on its own: %v", err)
+	}
+	back := web.Networks["back"]
+	if back == nil || !back.IsExternal() || back.Name != "shop_back" {
+		t.Errorf("shared network back = %+v, want external shop_back", back)
+	}
+	if data := web.Volumes["data"]; data == nil || !data.IsExternal() || data.Name != "shared-data" {
+		t.Errorf("shared volume data = %+v, want external shared-data", data)
+	}
+
+	db, err := GetConfigFromComposeFile(paths["db"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if own := db.Volumes["own"]; own != nil && own.IsExternal() {
+		t.Error("volume own is only used by db but marked external")
+	}
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const systemdDocker = "/usr/bin/docker"
+
+// SystemdUnitName returns the name of the unit ToSystemdUnits generates for
+// the service.
+func SystemdUnitName(projectName, serviceName string) string {
+	return projectName + "-" + serviceName + ".service"
+}
+
+// ToSystemdUnits renders one systemd unit per service, keyed by unit name.
+// The container runs in the foreground of the unit and systemd takes over the
+// restart policy. depends_on entries become Requires= and After=, which only
+// order the start: conditions such as service_healthy are not waited on.
+// Services with settings docker run cannot express are reported as errors.
+func (conf *ComposeConfig) ToSystemdUnits(projectName string) (map[string][]byte, error) {
+	units := map[string][]byte{}
+	errs := &MultiError{}
+	for _, name := range conf.ServiceNames() {
+		unit, err := conf.toSystemdUnit(projectName, name)
+		if err != nil {
+			errs.Append(err)
+			continue
+		}
+		units[SystemdUnitName(projectName, name)] = unit
+	}
+	if err := errs.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+	return units, nil
+}
+
+func (conf *ComposeConfig) toSystemdUnit(projectName, serviceName string) ([]byte, error) {
+	serviceConf := (*conf.Services)[serviceName]
+	args, command, untranslated, err := conf.dockerRunArgs(projectName, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	if len(untranslated) > 0 {
+		return nil, fmt.Errorf("service %s: cannot translate %s", serviceName, strings.Join(untranslated, "; "))
+	}
+	policy, err := serviceConf.EffectiveRestartPolicy()
+	if err != nil {
+		return nil, fmt.Errorf("service %s: %w", serviceName, err)
+	}
+
+	// systemd supervises the container, so it runs attached and without a
+	// docker restart policy
+	runArgs := []string{"run", "--rm"}
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "-d":
+		case "--restart":
+			i++
+		default:
+			runArgs = append(runArgs, args[i])
+		}
+	}
+
+	deps := []string{"docker.service"}
+	if serviceConf.DependsOn != nil {
+		for _, dep := range sortedKeys(*serviceConf.DependsOn) {
+			if _, ok := (*conf.Services)[dep]; !ok {
+				return nil, fmt.Errorf("service %s: %w: depends on undefined service %s", serviceName, ErrInvalidDependsOn, dep)
+			}
+			deps = append(deps, SystemdUnitName(projectName, dep))
+		}
+	}
+	containerName := serviceConf.RuntimeContainerName(projectName)
+
+	var b bytes.Buffer
+	b.WriteString("[Unit]\n")
+	fmt.Fprintf(&b, "Description=%s service of the %s compose project\n", serviceName, projectName)
+	fmt.Fprintf(&b, "Requires=%s\n", strings.Join(deps, " "))
+	fmt.Fprintf(&b, "After=%s\n", strings.Join(deps, " "))
+	if policy.MaxAttempts != nil {
+		fmt.Fprintf(&b, "StartLimitBurst=%d\n", *policy.MaxAttempts)
+		if policy.Window != "" {
+			fmt.Fprintf(&b, "StartLimitIntervalSec=%s\n", policy.Window)
+		}
+	}
+
+	b.WriteString("\n[Service]\n")
+	switch policy.Condition {
+	case RestartConditionNone:
+		b.WriteString("Restart=no\n")
+	case RestartConditionOnFailure:
+		b.WriteString("Restart=on-failure\n")
+	default:
+		b.WriteString("Restart=always\n")
+	}
+	if policy.Delay != "" {
+		fmt.Fprintf(&b, "RestartSec=%s\n", policy.Delay)
+	}
+	networks, volumes := serviceConf.usedResources()
+	for _, network := range networks {
+		if createArgs := conf.networkCreateArgs(projectName, network); createArgs != nil {
+			fmt.Fprintf(&b, "ExecStartPre=-%s %s\n", systemdDocker, systemdJoin(createArgs))
+		}
+	}
+	for _, volume := range volumes {
+		if createArgs := conf.volumeCreateArgs(projectName, volume); createArgs != nil {
+			fmt.Fprintf(&b, "ExecStartPre=-%s %s\n", systemdDocker, systemdJoin(createArgs))
+		}
+	}
+	fmt.Fprintf(&b, "ExecStartPre=-%s rm -f %s\n", systemdDocker, systemdQuote(containerName))
+	fmt.Fprintf(&b, "ExecStart=%s", systemdDocker)
+	for i, arg := range runArgs {
+		if i > 1 && (strings.HasPrefix(arg, "-") || i == len(runArgs)-1) {
+			b.WriteString(" \\\n ")
+		}
+		b.WriteString(" " + systemdQuote(arg))
+	}
+	for _, arg := range command {
+		b.WriteString(" " + systemdQuote(arg))
+	}
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "ExecStop=%s stop %s\n", systemdDocker, systemdQuote(containerName))
+
+	b.WriteString("\n[Install]\nWantedBy=multi-user.target\n")
+	return b.Bytes(), nil
+}
+
+// systemdQuote escapes an argument of an Exec line: specifiers and variable
+// references are doubled, and arguments with spaces or quotes are quoted.
+func systemdQuote(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	s = strings.ReplaceAll(s, "$", "$$")
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\;") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func systemdJoin(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		quoted = append(quoted, systemdQuote(arg))
+	}
+	return strings.Join(quoted, " ")
+}
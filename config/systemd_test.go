@@ -0,0 +1,44 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestToSystemdUnitsRunsTheCommand(t *testing.T) {
+	conf := mustParse(t, `services:
+  web:
+    image: nginx
+    command: ["-d", "--restart", "now"]
+    entrypoint: /entrypoint.sh
+    user: "1000"
+`)
+	units, err := conf.ToSystemdUnits("p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := string(units["p-web.service"])
+	// the command words look like the docker run flags the unit drops
+	for _, want := range []string{"--entrypoint /entrypoint.sh", "--user 1000", "\\\n  nginx -d --restart now\n"} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("%q is missing from:\n%s", want, unit)
+		}
+	}
+}
+
+func TestToSystemdUnitsRejectsUntranslatedSettings(t *testing.T) {
+	conf := mustParse(t, `services:
+  web:
+    image: nginx
+    command: ["echo", "hi"]
+    tty: true
+  db:
+    image: postgres
+`)
+	units, err := conf.ToSystemdUnits("p")
+	var multiErr *MultiError
+	if units != nil || !errors.As(err, &multiErr) || len(multiErr.Errors) != 1 || !strings.Contains(err.Error(), "service web: cannot translate tty") {
+		t.Errorf("got %d units and %v, want the tty error of web alone", len(units), err)
+	}
+}
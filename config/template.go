@@ -0,0 +1,103 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+var regYAMLErrorLine = regexp.MustCompile(`line (\d+)`)
+
+// templateFuncs are the helpers available to RenderTemplate, named after
+// their sprig counterparts.
+var templateFuncs = template.FuncMap{
+	"indent": func(spaces int, s string) string {
+		pad := strings.Repeat(" ", spaces)
+		return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+	},
+	"nindent": func(spaces int, s string) string {
+		pad := strings.Repeat(" ", spaces)
+		return "\n" + pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+	},
+	"quote": func(v any) string {
+		return strconv.Quote(fmt.Sprint(v))
+	},
+	"squote": func(v any) string {
+		return "'" + strings.ReplaceAll(fmt.Sprint(v), "'", "''") + "'"
+	},
+	"default": func(fallback, v any) any {
+		if v == nil || v == "" {
+			return fallback
+		}
+		return v
+	},
+	"required": func(msg string, v any) (any, error) {
+		if v == nil || v == "" {
+			return nil, fmt.Errorf("%s", msg)
+		}
+		return v, nil
+	},
+	"toYaml": func(v any) (string, error) {
+		content, err := yaml.Marshal(v)
+		return strings.TrimSuffix(string(content), "\n"), err
+	},
+	"toJson": func(v any) (string, error) {
+		content, err := jsoniter.Marshal(v)
+		return string(content), err
+	},
+}
+
+// RenderTemplate executes the text/template at tmplPath with data and parses
+// the result as a compose file. Parse errors quote the rendered line they
+// point at, since their line numbers refer to the output, not the template.
+func RenderTemplate(tmplPath string, data any) (*ComposeConfig, error) {
+	content, err := os.ReadFile(tmplPath)
+	if err != nil {
+		return nil, err
+	}
+	return renderTemplate(filepath.Base(tmplPath), string(content), data)
+}
+
+func RenderTemplateString(text string, data any) (*ComposeConfig, error) {
+	return renderTemplate("compose", text, data)
+}
+
+func renderTemplate(name, text string, data any) (*ComposeConfig, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	var rendered bytes.Buffer
+	if err = tmpl.Execute(&rendered, data); err != nil {
+		return nil, err
+	}
+	conf, err := GetConfigFromBytes(rendered.Bytes(), FormatAuto)
+	if err != nil {
+		return nil, withRenderedLine(err, rendered.Bytes())
+	}
+	return conf, nil
+}
+
+// withRenderedLine appends the rendered line err refers to, if any.
+func withRenderedLine(err error, rendered []byte) error {
+	line := 0
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		line = parseErr.Line
+	} else if match := regYAMLErrorLine.FindStringSubmatch(err.Error()); match != nil {
+		line, _ = strconv.Atoi(match[1])
+	}
+	lines := strings.Split(string(rendered), "\n")
+	if line < 1 || line > len(lines) {
+		return err
+	}
+	return fmt.Errorf("%w\nrendered line %d: %s", err, line, lines[line-1])
+}
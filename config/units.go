@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var regByteSize = regexp.MustCompile(`^(?i)(-?\d+(?:\.\d+)?) ?(?:([kmgtp])(?:i?b)?|b)?$`)
+
+var byteSizeUnits = map[string]int64{
+	"":  1,
+	"k": 1 << 10,
+	"m": 1 << 20,
+	"g": 1 << 30,
+	"t": 1 << 40,
+	"p": 1 << 50,
+}
+
+// ParseBytes parses sizes such as "512k", "256mb" or "1.5g" the way docker
+// does: a bare number is a count of bytes and every suffix is a binary
+// multiple, so "kb" and "kib" both mean 1024 bytes.
+func ParseBytes(s string) (int64, error) {
+	match := regByteSize.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return 0, fmt.Errorf("invalid byte size: %q", s)
+	}
+	if strings.HasPrefix(match[1], "-") {
+		return 0, fmt.Errorf("negative byte size: %q", s)
+	}
+	// big.Rat keeps large whole numbers exact where a float64 would round
+	value, ok := new(big.Rat).SetString(match[1])
+	if !ok {
+		return 0, fmt.Errorf("invalid byte size: %q", s)
+	}
+	value.Mul(value, new(big.Rat).SetInt64(byteSizeUnits[strings.ToLower(match[2])]))
+	size := new(big.Int).Quo(value.Num(), value.Denom())
+	if !size.IsInt64() {
+		return 0, fmt.Errorf("byte size out of range: %q", s)
+	}
+	return size.Int64(), nil
+}
+
+// ParseByteSize is the former name of ParseBytes.
+func ParseByteSize(s string) (int64, error) {
+	return ParseBytes(s)
+}
+
+// FormatBytes renders n with the largest suffix that divides it exactly, so
+// ParseBytes(FormatBytes(n)) == n. Like ParseBytes it rejects negative sizes.
+func FormatBytes(n int64) (string, error) {
+	if n < 0 {
+		return "", fmt.Errorf("negative byte size: %d", n)
+	}
+	for _, unit := range []string{"p", "t", "g", "m", "k"} {
+		if multiple := byteSizeUnits[unit]; n != 0 && n%multiple == 0 {
+			return strconv.FormatInt(n/multiple, 10) + unit, nil
+		}
+	}
+	return strconv.FormatInt(n, 10), nil
+}
+
+// ComposeByteSize is a size in bytes as written: a number, or a byte size
+// such as "64m" as read by ParseByteSize.
+type ComposeByteSize string
+
+// Bytes parses the size into a number of bytes.
+func (s ComposeByteSize) Bytes() (int64, error) {
+	return ParseByteSize(string(s))
+}
+
+func (s ComposeByteSize) MarshalYAML() (any, error) {
+	if n, err := strconv.ParseInt(string(s), 10, 64); err == nil {
+		return n, nil
+	}
+	return string(s), nil
+}
+
+func (s *ComposeByteSize) UnmarshalJSON(data []byte) error {
+	var n int64
+	if err := jsoniter.Unmarshal(data, &n); err == nil {
+		*s = ComposeByteSize(strconv.FormatInt(n, 10))
+		return nil
+	}
+	return jsoniter.Unmarshal(data, (*string)(s))
+}
+
+func (s ComposeByteSize) MarshalJSON() ([]byte, error) {
+	value, _ := s.MarshalYAML()
+	return jsoniter.Marshal(value)
+}
+
+// ParseCPUs parses a cpus quantity such as "0.5" or "2".
+func ParseCPUs(s string) (float64, error) {
+	cpus, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || math.IsNaN(cpus) || math.IsInf(cpus, 0) {
+		return 0, fmt.Errorf("invalid cpus: %q", s)
+	}
+	if cpus < 0 {
+		return 0, fmt.Errorf("negative cpus: %q", s)
+	}
+	return cpus, nil
+}
@@ -0,0 +1,22 @@
+package config
+
+import "testing"
+
+func TestFormatBytesRoundTrips(t *testing.T) {
+	for _, n := range []int64{0, 1, 1023, 1024, 1536, 1 << 20, 3 << 30, 1<<50 + 1, 1 << 62} {
+		s, err := FormatBytes(n)
+		if err != nil {
+			t.Errorf("FormatBytes(%d): %v", n, err)
+			continue
+		}
+		back, err := ParseBytes(s)
+		if err != nil || back != n {
+			t.Errorf("ParseBytes(FormatBytes(%d) = %q) = %d, %v", n, s, back, err)
+		}
+	}
+	for _, n := range []int64{-1, -1024} {
+		if s, err := FormatBytes(n); err == nil {
+			t.Errorf("FormatBytes(%d) = %q, want an error", n, s)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"strconv"
+)
+
+// UpgradeToSpec returns a copy of the config rewritten from the legacy 2.x
+// and 3.x file formats to the compose spec, leaving conf untouched. Legacy
+// settings with no spec equivalent are reported as warnings rather than
+// silently dropped.
+func (conf *ComposeConfig) UpgradeToSpec() (*ComposeConfig, []Warning, error) {
+	if conf.Version != "" {
+		if _, _, err := parseFileFormatVersion(conf.Version); err != nil {
+			return nil, nil, err
+		}
+	}
+	upgraded, err := conf.Clone()
+	if err != nil {
+		return nil, nil, err
+	}
+	warnings := []Warning{}
+	upgraded.Version = ""
+
+	for _, name := range upgraded.ServiceNames() {
+		serviceConf := (*upgraded.Services)[name]
+		path := "services." + name
+		warn := func(field, format string, args ...any) {
+			warnings = append(warnings, Warning{Path: path + "." + field, Message: fmt.Sprintf(format, args...)})
+		}
+
+		serviceConf.NormalizeScaleToDeploy()
+		if serviceConf.Scale != nil {
+			warn("scale", "kept, it conflicts with deploy.replicas")
+		}
+		serviceConf.NormalizeResourcesToDeploy()
+		if serviceConf.MemLimit != "" {
+			warn("mem_limit", "kept, it conflicts with deploy.resources.limits.memory")
+		}
+		if serviceConf.MemReservation != "" {
+			warn("mem_reservation", "kept, it conflicts with deploy.resources.reservations.memory")
+		}
+		if serviceConf.CPUCount != nil {
+			warn("cpu_count", "kept, deploy.resources has no equivalent")
+		}
+		if serviceConf.CPUPercent != nil {
+			warn("cpu_percent", "kept, deploy.resources has no equivalent")
+		}
+		if cpus, ok := serviceConf.RawExtra["cpus"]; ok {
+			if serviceConf.moveCPUsToDeploy(cpus) {
+				delete(serviceConf.RawExtra, "cpus")
+			} else {
+				warn("cpus", "kept, it conflicts with deploy.resources.limits.cpus")
+			}
+		}
+		for _, key := range []string{"cpu_shares", "cpu_quota", "cpu_period", "cpu_rt_runtime", "cpu_rt_period", "cpuset"} {
+			if _, ok := serviceConf.RawExtra[key]; ok {
+				warn(key, "kept, deploy.resources has no equivalent")
+			}
+		}
+
+		if _, ok := serviceConf.RawExtra["volume_driver"]; ok {
+			delete(serviceConf.RawExtra, "volume_driver")
+			warn("volume_driver", "dropped, set the driver on the top-level volumes instead")
+		}
+		if _, ok := serviceConf.RawExtra["extends"]; ok {
+			warn("extends", "kept as is, extended services are not resolved")
+		}
+	}
+
+	for _, name := range sortedKeys(upgraded.Networks) {
+		if networkConf := upgraded.Networks[name]; networkConf != nil {
+			if warning, ok := upgradeExternalName(&networkConf.External, &networkConf.Name); !ok {
+				warnings = append(warnings, Warning{Path: "networks." + name, Message: warning})
+			}
+		}
+	}
+	for _, name := range sortedKeys(upgraded.Volumes) {
+		if volumeConf := upgraded.Volumes[name]; volumeConf != nil {
+			if warning, ok := upgradeExternalName(&volumeConf.External, &volumeConf.Name); !ok {
+				warnings = append(warnings, Warning{Path: "volumes." + name, Message: warning})
+			}
+		}
+	}
+	return upgraded, warnings, nil
+}
+
+// moveCPUsToDeploy moves the legacy cpus value to deploy.resources.limits.cpus,
+// unless the limit is already set to another value.
+func (serviceConf *ComposeServiceConfig) moveCPUsToDeploy(cpus yaml.Node) bool {
+	if cpus.Kind != yaml.ScalarNode {
+		return false
+	}
+	value, err := strconv.ParseFloat(cpus.Value, 64)
+	if err != nil {
+		return false
+	}
+	if serviceConf.Deploy == nil {
+		serviceConf.Deploy = &ComposeDeployConfig{}
+	}
+	if serviceConf.Deploy.Resources == nil {
+		serviceConf.Deploy.Resources = &ComposeResourcesConfig{}
+	}
+	resources := serviceConf.Deploy.Resources
+	if resources.Limits == nil {
+		resources.Limits = &ComposeResourceConfig{}
+	}
+	if current := resources.Limits.Cpus; current != "" {
+		currentValue, err := strconv.ParseFloat(current, 64)
+		return err == nil && currentValue == value
+	}
+	resources.Limits.Cpus = cpus.Value
+	return true
+}
+
+// upgradeExternalName turns the legacy external: {name: ...} form into
+// external: true and name: ...
+func upgradeExternalName(external **ComposeExternalConfig, name *string) (string, bool) {
+	if *external == nil || (*external).Name == "" {
+		return "", true
+	}
+	externalName := (*external).Name
+	if *name != "" && *name != externalName {
+		return fmt.Sprintf("external.name %q kept, it conflicts with name %q", externalName, *name), false
+	}
+	*name = externalName
+	*external = &ComposeExternalConfig{External: true}
+	return "", true
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUpgradeToSpecMigratesCPUs(t *testing.T) {
+	conf := mustParse(t, `version: "2.4"
+services:
+  web:
+    image: nginx
+    cpus: 0.5
+    cpu_shares: 512
+    cpu_quota: 50000
+`)
+	upgraded, warnings, err := conf.UpgradeToSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	web := (*upgraded.Services)["web"]
+	if web.Deploy == nil || web.Deploy.Resources == nil || web.Deploy.Resources.Limits == nil || web.Deploy.Resources.Limits.Cpus != "0.5" {
+		t.Fatalf("cpus not moved to deploy.resources.limits.cpus: %+v", web.Deploy)
+	}
+	if _, ok := web.RawExtra["cpus"]; ok {
+		t.Error("cpus kept next to deploy.resources.limits.cpus")
+	}
+	if _, ok := (*conf.Services)["web"].RawExtra["cpus"]; !ok {
+		t.Error("the original config was modified")
+	}
+	warned := map[string]bool{}
+	for _, warning := range warnings {
+		warned[warning.Path] = true
+	}
+	for _, path := range []string{"services.web.cpu_shares", "services.web.cpu_quota"} {
+		if !warned[path] {
+			t.Errorf("no warning for %s in %v", path, warnings)
+		}
+	}
+
+	content, err := upgraded.ExportYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "version:") {
+		t.Errorf("upgraded export still has a version key:\n%s", content)
+	}
+}
@@ -0,0 +1,460 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	regMacAddress    = regexp.MustCompile(`^[0-9A-Fa-f]{2}(:[0-9A-Fa-f]{2}){5}$`)
+	regResourceName  = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+	regHostnameLabel = regexp.MustCompile(`^[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+)
+
+const maxHostnameLength = 253
+
+func (serviceConf *ComposeServiceConfig) Validate() error {
+	errs := &MultiError{}
+	// images still holding variables are checked once interpolated
+	if serviceConf.Image != "" && !strings.Contains(serviceConf.Image, "$") {
+		if err := ValidateImageRef(serviceConf.Image); err != nil {
+			errs.Append(err)
+		}
+	}
+	if _, err := serviceConf.ParsePullPolicy(); err != nil {
+		errs.Append(err)
+	}
+	if serviceConf.ShmSize != "" {
+		if _, err := serviceConf.ShmSizeBytes(); err != nil {
+			errs.Append(fmt.Errorf("shm_size: %w", err))
+		}
+	}
+	if serviceConf.MemLimit != "" {
+		if _, err := serviceConf.MemLimitBytes(); err != nil {
+			errs.Append(fmt.Errorf("mem_limit: %w", err))
+		}
+	}
+	if serviceConf.MemReservation != "" {
+		if _, err := ParseBytes(serviceConf.MemReservation); err != nil {
+			errs.Append(fmt.Errorf("mem_reservation: %w", err))
+		}
+	}
+	if serviceConf.CPUCount != nil && *serviceConf.CPUCount < 0 {
+		errs.Append(fmt.Errorf("cpu_count must not be negative"))
+	}
+	if serviceConf.CPUPercent != nil && (*serviceConf.CPUPercent < 0 || *serviceConf.CPUPercent > 100) {
+		errs.Append(fmt.Errorf("cpu_percent must be between 0 and 100"))
+	}
+	if serviceConf.Scale != nil && serviceConf.Deploy != nil && serviceConf.Deploy.Replicas != nil && *serviceConf.Scale != *serviceConf.Deploy.Replicas {
+		errs.Append(fmt.Errorf("scale (%d) conflicts with deploy.replicas (%d)", *serviceConf.Scale, *serviceConf.Deploy.Replicas))
+	}
+	if serviceConf.ContainerName != "" {
+		replicas := uint64(1)
+		if serviceConf.Scale != nil {
+			replicas = *serviceConf.Scale
+		}
+		if serviceConf.Deploy != nil && serviceConf.Deploy.Replicas != nil && *serviceConf.Deploy.Replicas > replicas {
+			replicas = *serviceConf.Deploy.Replicas
+		}
+		if replicas > 1 {
+			errs.Append(fmt.Errorf("container_name %s cannot be shared by %d replicas", serviceConf.ContainerName, replicas))
+		}
+	}
+	if _, err := serviceConf.EffectiveRestartPolicy(); err != nil {
+		errs.Append(err)
+	}
+	switch serviceConf.Isolation {
+	case "", "default", "process", "hyperv":
+	default:
+		errs.Append(fmt.Errorf("invalid isolation: %s", serviceConf.Isolation))
+	}
+	if serviceConf.Hostname != "" && !strings.Contains(serviceConf.Hostname, "$") {
+		if err := validateHostname(serviceConf.Hostname); err != nil {
+			errs.Append(err)
+		}
+	}
+	if serviceConf.MacAddress != "" && !regMacAddress.MatchString(serviceConf.MacAddress) {
+		errs.Append(fmt.Errorf("invalid mac_address: %s", serviceConf.MacAddress))
+	}
+	for _, err := range serviceConf.hostNetworkErrors() {
+		errs.Append(err)
+	}
+	for _, volume := range serviceConf.Volumes {
+		// long syntax entries are checked when parsed, and specs still holding
+		// variables once interpolated
+		if volume.Long == nil && !strings.Contains(volume.Spec, "$") {
+			if err := ValidateVolumeSpec(volume.Spec); err != nil {
+				errs.Append(err)
+			}
+		}
+	}
+	if serviceConf.Logging != nil {
+		if err := serviceConf.Logging.Validate(); err != nil {
+			errs.Append(err)
+		}
+	}
+	if size, ok := serviceConf.StorageOpt["size"]; ok {
+		if _, err := ParseByteSize(size); err != nil {
+			errs.Append(fmt.Errorf("storage_opt.size: %w", err))
+		}
+	}
+	if serviceConf.BlkioConfig != nil {
+		if err := serviceConf.BlkioConfig.Validate(); err != nil {
+			errs.Append(err)
+		}
+	}
+	if serviceConf.OOMScoreAdj != nil && (*serviceConf.OOMScoreAdj < -1000 || *serviceConf.OOMScoreAdj > 1000) {
+		errs.Append(fmt.Errorf("oom_score_adj must be between -1000 and 1000: %d", *serviceConf.OOMScoreAdj))
+	}
+	if serviceConf.Deploy != nil && serviceConf.Deploy.Resources != nil {
+		resources := serviceConf.Deploy.Resources
+		for _, section := range []struct {
+			name     string
+			resource *ComposeResourceConfig
+		}{{"limits", resources.Limits}, {"reservations", resources.Reservations}} {
+			if section.resource == nil {
+				continue
+			}
+			if section.resource.Memory != "" {
+				if _, err := section.resource.MemoryBytes(); err != nil {
+					errs.Append(fmt.Errorf("deploy.resources.%s.memory: %w", section.name, err))
+				}
+			}
+			if section.resource.Cpus != "" {
+				if _, err := section.resource.CPUs(); err != nil {
+					errs.Append(fmt.Errorf("deploy.resources.%s.cpus: %w", section.name, err))
+				}
+			}
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// hostNetworkErrors reports settings that have no effect with network_mode:
+// host, where the container shares the host network stack.
+func (serviceConf *ComposeServiceConfig) hostNetworkErrors() []error {
+	if serviceConf.NetworkMode != "host" {
+		return nil
+	}
+	errs := []error{}
+	if len(serviceConf.Ports) > 0 {
+		errs = append(errs, fmt.Errorf("ports cannot be published with network_mode: host"))
+	}
+	if len(serviceConf.Networks) > 0 {
+		errs = append(errs, fmt.Errorf("networks cannot be combined with network_mode: host"))
+	}
+	return errs
+}
+
+func (conf *ComposeConfig) ValidateHostNetworkUsage() []error {
+	errs := []error{}
+	for _, name := range conf.ServiceNames() {
+		for _, err := range (*conf.Services)[name].hostNetworkErrors() {
+			errs = append(errs, fmt.Errorf("service %s: %w", name, err))
+		}
+	}
+	return errs
+}
+
+func (blkioConf *ComposeBlkioConfig) Validate() error {
+	errs := &MultiError{}
+	checkWeight := func(field string, weight uint16) {
+		if weight != 0 && (weight < 10 || weight > 1000) {
+			errs.Append(fmt.Errorf("blkio_config.%s must be between 10 and 1000: %d", field, weight))
+		}
+	}
+	checkWeight("weight", blkioConf.Weight)
+	for _, device := range blkioConf.WeightDevice {
+		checkWeight("weight_device", device.Weight)
+	}
+	for _, section := range []struct {
+		name    string
+		devices []*ComposeBlkioThrottleDeviceConfig
+		bytes   bool
+	}{
+		{"device_read_bps", blkioConf.DeviceReadBps, true},
+		{"device_write_bps", blkioConf.DeviceWriteBps, true},
+		{"device_read_iops", blkioConf.DeviceReadIOps, false},
+		{"device_write_iops", blkioConf.DeviceWriteIOps, false},
+	} {
+		for _, device := range section.devices {
+			if device.Path == "" {
+				errs.Append(fmt.Errorf("blkio_config.%s: missing path", section.name))
+			}
+			var err error
+			if section.bytes {
+				_, err = ParseBytes(string(device.Rate))
+			} else {
+				_, err = strconv.ParseUint(string(device.Rate), 10, 64)
+			}
+			if err != nil {
+				errs.Append(fmt.Errorf("blkio_config.%s: invalid rate %q for %s", section.name, device.Rate, device.Path))
+			}
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// Durations docker uses for healthcheck settings left unset.
+const (
+	defaultHealthcheckInterval = 30 * time.Second
+	defaultHealthcheckTimeout  = 30 * time.Second
+)
+
+// Validate flags healthchecks that can never pass or contradict themselves:
+// zero retries, a timeout longer than the interval and disable combined with
+// other settings. Unset durations take the docker defaults.
+func (healthcheck *ComposeHealthcheckConfig) Validate() []error {
+	errs := []error{}
+	disabled := healthcheck.IsDisabled()
+	if healthcheck.Disable {
+		set := []string{}
+		if len(healthcheck.Test) > 0 {
+			set = append(set, "test")
+		}
+		for _, field := range []struct {
+			name  string
+			value string
+		}{
+			{"interval", healthcheck.Interval},
+			{"timeout", healthcheck.Timeout},
+			{"start_period", healthcheck.StartPeriod},
+		} {
+			if field.value != "" {
+				set = append(set, field.name)
+			}
+		}
+		if healthcheck.Retries != nil {
+			set = append(set, "retries")
+		}
+		if len(set) > 0 {
+			errs = append(errs, fmt.Errorf("healthcheck: disable cannot be combined with %s", strings.Join(set, ", ")))
+		}
+	}
+	if !disabled && healthcheck.Retries != nil && *healthcheck.Retries == 0 {
+		errs = append(errs, fmt.Errorf("healthcheck: retries must be at least 1"))
+	}
+
+	parse := func(field, value string, fallback time.Duration) (time.Duration, bool) {
+		if value == "" {
+			return fallback, true
+		}
+		// checked once interpolated
+		if strings.Contains(value, "$") {
+			return 0, false
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			errs = append(errs, fmt.Errorf("healthcheck: invalid %s %q", field, value))
+			return 0, false
+		}
+		return d, true
+	}
+	interval, intervalOK := parse("interval", healthcheck.Interval, defaultHealthcheckInterval)
+	timeout, timeoutOK := parse("timeout", healthcheck.Timeout, defaultHealthcheckTimeout)
+	parse("start_period", healthcheck.StartPeriod, 0)
+	if !disabled && intervalOK && timeoutOK && timeout > interval {
+		errs = append(errs, fmt.Errorf("healthcheck: timeout %s is longer than the interval %s", timeout, interval))
+	}
+	return errs
+}
+
+func (networkConf *ComposeNetworkConfig) Validate() error {
+	if networkConf.IsExternal() && len(networkConf.DriverOpts) > 0 {
+		return fmt.Errorf("driver_opts is ignored for external networks")
+	}
+	if _, err := networkConf.subnets(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// subnets returns the subnets of the ipam config.
+func (networkConf *ComposeNetworkConfig) subnets() ([]netip.Prefix, error) {
+	subnets := []netip.Prefix{}
+	if networkConf.Ipam == nil {
+		return subnets, nil
+	}
+	for _, pool := range networkConf.Ipam.Config {
+		if pool == nil || pool.Subnet == "" {
+			continue
+		}
+		subnet, err := netip.ParsePrefix(pool.Subnet)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ipam subnet %q", pool.Subnet)
+		}
+		subnets = append(subnets, subnet.Masked())
+	}
+	return subnets, nil
+}
+
+func (volumeConf *ComposeVolumeConfig) Validate() error {
+	if volumeConf.IsExternal() && len(volumeConf.DriverOpts) > 0 {
+		return fmt.Errorf("driver_opts is ignored for external volumes")
+	}
+	return nil
+}
+
+// validateHostname checks hostname against RFC 1123: dot separated labels of
+// letters, digits and inner hyphens, up to 63 characters each.
+func validateHostname(hostname string) error {
+	if len(hostname) > maxHostnameLength {
+		return fmt.Errorf("invalid hostname %q: longer than %d characters", hostname, maxHostnameLength)
+	}
+	for _, label := range strings.Split(hostname, ".") {
+		if !regHostnameLabel.MatchString(label) {
+			return fmt.Errorf("invalid hostname %q: label %q must be 1 to 63 letters, digits or inner hyphens", hostname, label)
+		}
+	}
+	return nil
+}
+
+// ValidateResourceNames checks the keys of every network, volume, secret,
+// config and service, as well as container_name values, against the naming
+// rule of the docker engine. A container_name used by several services is
+// reported too, since only one of them could run.
+func (conf *ComposeConfig) ValidateResourceNames() []error {
+	errs := []error{}
+	check := func(kind string, names []string) {
+		for _, name := range names {
+			if !regResourceName.MatchString(name) {
+				errs = append(errs, fmt.Errorf("%s %q: name must match %s", kind, name, regResourceName))
+			}
+		}
+	}
+	check("network", sortedKeys(conf.Networks))
+	check("volume", sortedKeys(conf.Volumes))
+	check("secret", sortedKeys(conf.Secrets))
+	check("config", sortedKeys(conf.Configs))
+	check("service", conf.ServiceNames())
+	owners := map[string]string{}
+	for _, name := range conf.ServiceNames() {
+		containerName := (*conf.Services)[name].ContainerName
+		if containerName == "" {
+			continue
+		}
+		if !regResourceName.MatchString(containerName) {
+			errs = append(errs, fmt.Errorf("service %s: container_name %q must match %s", name, containerName, regResourceName))
+		}
+		if owner, ok := owners[containerName]; ok {
+			errs = append(errs, fmt.Errorf("service %s: container_name %q is already used by service %s", name, containerName, owner))
+			continue
+		}
+		owners[containerName] = name
+	}
+	return errs
+}
+
+// ValidateStaticIPs reports the ipv4_address and ipv6_address values several
+// services claim on the same network, as well as addresses outside the
+// subnets of a network whose ipam config declares some.
+func (conf *ComposeConfig) ValidateStaticIPs() []error {
+	errs := []error{}
+	owners := map[string]map[netip.Addr]string{}
+	for _, name := range conf.ServiceNames() {
+		for _, network := range (*conf.Services)[name].Networks {
+			subnets := []netip.Prefix{}
+			if networkConf := conf.Networks[network.Name]; networkConf != nil {
+				// invalid subnets are reported by Validate
+				subnets, _ = networkConf.subnets()
+			}
+			for _, address := range []string{network.Ipv4Address, network.Ipv6Address} {
+				if address == "" {
+					continue
+				}
+				ip, err := netip.ParseAddr(address)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("service %s: network %s: invalid address %q", name, network.Name, address))
+					continue
+				}
+				if owner, ok := owners[network.Name][ip]; ok {
+					errs = append(errs, fmt.Errorf("service %s: network %s: address %s is already assigned to service %s", name, network.Name, ip, owner))
+					continue
+				}
+				if owners[network.Name] == nil {
+					owners[network.Name] = map[netip.Addr]string{}
+				}
+				owners[network.Name][ip] = name
+				if len(subnets) > 0 && !subnetsContain(subnets, ip) {
+					errs = append(errs, fmt.Errorf("service %s: network %s: address %s is outside the subnets %s", name, network.Name, ip, joinPrefixes(subnets)))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+func subnetsContain(subnets []netip.Prefix, ip netip.Addr) bool {
+	for _, subnet := range subnets {
+		if subnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPrefixes(prefixes []netip.Prefix) string {
+	list := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		list = append(list, prefix.String())
+	}
+	return strings.Join(list, ", ")
+}
+
+func (conf *ComposeConfig) Validate() error {
+	errs := &MultiError{}
+	for _, err := range conf.ValidateResourceNames() {
+		errs.Append(err)
+	}
+	for _, name := range sortedKeys(conf.Networks) {
+		if networkConf := conf.Networks[name]; networkConf != nil {
+			if err := networkConf.Validate(); err != nil {
+				errs.Append(fmt.Errorf("network %s: %w", name, err))
+			}
+		}
+	}
+	for _, name := range sortedKeys(conf.Volumes) {
+		if volumeConf := conf.Volumes[name]; volumeConf != nil {
+			if err := volumeConf.Validate(); err != nil {
+				errs.Append(fmt.Errorf("volume %s: %w", name, err))
+			}
+		}
+	}
+	for _, name := range conf.ServiceNames() {
+		serviceConf := (*conf.Services)[name]
+		appendServiceErrors(errs, name, serviceConf.Validate())
+		if serviceConf.Healthcheck != nil {
+			for _, err := range serviceConf.Healthcheck.Validate() {
+				errs.Append(fmt.Errorf("service %s: %w", name, err))
+			}
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func appendServiceErrors(errs *MultiError, serviceName string, err error) {
+	if err == nil {
+		return
+	}
+	if multiErr, ok := err.(*MultiError); ok {
+		for _, e := range multiErr.Errors {
+			errs.Append(fmt.Errorf("service %s: %w", serviceName, e))
+		}
+		return
+	}
+	errs.Append(fmt.Errorf("service %s: %w", serviceName, err))
+}
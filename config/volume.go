@@ -0,0 +1,460 @@
+package config
+
+import (
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"gopkg.in/yaml.v3"
+	"strconv"
+	"strings"
+)
+
+const (
+	VolumeTypeBind   = "bind"
+	VolumeTypeVolume = "volume"
+	VolumeTypeTmpfs  = "tmpfs"
+	VolumeTypeNpipe  = "npipe"
+)
+
+// VolumeMount is a service volume. ParseVolumeMount fills in the fields of
+// the short syntax; the others only exist in the long syntax.
+type VolumeMount struct {
+	Type   string `json:"type" yaml:"type"`
+	Source string `json:"source,omitempty" yaml:"source,omitempty"`
+	Target string `json:"target" yaml:"target"`
+	// Mode holds the options of the short syntax, such as "ro" or "rw,z".
+	Mode        string               `json:"-" yaml:"-"`
+	ReadOnly    bool                 `json:"read_only,omitempty" yaml:"read_only,omitempty"`
+	Consistency string               `json:"consistency,omitempty" yaml:"consistency,omitempty"`
+	Bind        *VolumeBindOptions   `json:"bind,omitempty" yaml:"bind,omitempty"`
+	Volume      *VolumeVolumeOptions `json:"volume,omitempty" yaml:"volume,omitempty"`
+	Tmpfs       *VolumeTmpfsOptions  `json:"tmpfs,omitempty" yaml:"tmpfs,omitempty"`
+}
+
+type VolumeBindOptions struct {
+	Propagation    string `json:"propagation,omitempty" yaml:"propagation,omitempty"`
+	CreateHostPath *bool  `json:"create_host_path,omitempty" yaml:"create_host_path,omitempty"`
+	SELinux        string `json:"selinux,omitempty" yaml:"selinux,omitempty"`
+}
+
+type VolumeVolumeOptions struct {
+	NoCopy  bool   `json:"nocopy,omitempty" yaml:"nocopy,omitempty"`
+	Subpath string `json:"subpath,omitempty" yaml:"subpath,omitempty"`
+}
+
+type VolumeTmpfsOptions struct {
+	Size ComposeByteSize `json:"size,omitempty" yaml:"size,omitempty"`
+	// Mode is the file mode as written, e.g. 1777.
+	Mode *uint32 `json:"mode,omitempty" yaml:"mode,omitempty"`
+}
+
+// volumeConsistencies and bindPropagations are the short syntax options that
+// map onto the long syntax consistency and bind propagation.
+var (
+	volumeConsistencies = map[string]bool{"consistent": true, "cached": true, "delegated": true}
+	bindPropagations    = map[string]bool{"shared": true, "rshared": true, "slave": true, "rslave": true, "private": true, "rprivate": true}
+)
+
+// String renders the mount in the short syntax as far as it goes; see
+// ShortSyntax for whether that loses anything.
+func (m *VolumeMount) String() string {
+	spec, _ := m.ShortSyntax()
+	return spec
+}
+
+// ShortSyntax renders the mount as [SOURCE:]TARGET[:MODE], folding the long
+// syntax options the short one has a flag for into the mode. ok is false when
+// the mount has settings the short syntax cannot express.
+func (m *VolumeMount) ShortSyntax() (spec string, ok bool) {
+	ok = m.Type == "" || m.Type == VolumeTypeBind || m.Type == VolumeTypeVolume
+	options := []string{}
+	if m.Mode != "" {
+		options = strings.Split(m.Mode, ",")
+	}
+	if m.ReadOnly && !containsString(options, "ro") {
+		options = append(options, "ro")
+	}
+	if m.Consistency != "" {
+		options = append(options, m.Consistency)
+	}
+	if m.Bind != nil {
+		if m.Bind.Propagation != "" {
+			options = append(options, m.Bind.Propagation)
+		}
+		if m.Bind.SELinux != "" {
+			options = append(options, m.Bind.SELinux)
+		}
+		if m.Bind.CreateHostPath != nil && !*m.Bind.CreateHostPath {
+			ok = false
+		}
+	}
+	if m.Volume != nil {
+		if m.Volume.NoCopy {
+			options = append(options, "nocopy")
+		}
+		if m.Volume.Subpath != "" {
+			ok = false
+		}
+	}
+	if m.Tmpfs != nil {
+		ok = false
+	}
+
+	spec = m.Target
+	if m.Source != "" {
+		spec = m.Source + ":" + spec
+		if len(options) > 0 {
+			spec += ":" + strings.Join(options, ",")
+		}
+	} else if len(options) > 0 {
+		ok = false
+	}
+	return spec, ok
+}
+
+// LongSyntax returns a copy of the mount with the short syntax mode spread
+// over the long syntax fields. Unknown mode options are dropped.
+func (m *VolumeMount) LongSyntax() *VolumeMount {
+	long := *m
+	long.Mode = ""
+	if m.Bind != nil {
+		bind := *m.Bind
+		long.Bind = &bind
+	}
+	if m.Volume != nil {
+		volume := *m.Volume
+		long.Volume = &volume
+	}
+	if m.Mode == "" {
+		return &long
+	}
+	for _, option := range strings.Split(m.Mode, ",") {
+		switch {
+		case option == "ro":
+			long.ReadOnly = true
+		case volumeConsistencies[option]:
+			long.Consistency = option
+		case option == "nocopy":
+			if long.Volume == nil {
+				long.Volume = &VolumeVolumeOptions{}
+			}
+			long.Volume.NoCopy = true
+		case option == "z" || option == "Z":
+			if long.Bind == nil {
+				long.Bind = &VolumeBindOptions{}
+			}
+			long.Bind.SELinux = option
+		case bindPropagations[option]:
+			if long.Bind == nil {
+				long.Bind = &VolumeBindOptions{}
+			}
+			long.Bind.Propagation = option
+		}
+	}
+	return &long
+}
+
+// validateLong checks the fields the long syntax requires and that the
+// options match the type.
+func (m *VolumeMount) validateLong() error {
+	switch m.Type {
+	case VolumeTypeBind, VolumeTypeVolume, VolumeTypeTmpfs, VolumeTypeNpipe, "cluster", "image":
+	case "":
+		return fmt.Errorf("missing type")
+	default:
+		return fmt.Errorf("unknown type %q", m.Type)
+	}
+	if m.Target == "" {
+		return fmt.Errorf("missing target")
+	}
+	if m.Type == VolumeTypeBind && m.Source == "" {
+		return fmt.Errorf("bind mounts need a source")
+	}
+	if m.Type == VolumeTypeTmpfs && m.Source != "" {
+		return fmt.Errorf("tmpfs mounts take no source")
+	}
+	if m.Tmpfs != nil && m.Tmpfs.Size != "" && !strings.Contains(string(m.Tmpfs.Size), "$") {
+		if _, err := m.Tmpfs.Size.Bytes(); err != nil {
+			return fmt.Errorf("tmpfs.size: %w", err)
+		}
+	}
+	for option, ok := range map[string]bool{
+		"bind":   m.Bind == nil || m.Type == VolumeTypeBind,
+		"volume": m.Volume == nil || m.Type == VolumeTypeVolume,
+		"tmpfs":  m.Tmpfs == nil || m.Type == VolumeTypeTmpfs,
+	} {
+		if !ok {
+			return fmt.Errorf("%s options do not apply to %s mounts", option, m.Type)
+		}
+	}
+	return nil
+}
+
+// mountFlag renders the mount as the value of docker run --mount.
+func (m *VolumeMount) mountFlag() string {
+	long := m.LongSyntax()
+	fields := []string{"type=" + long.Type}
+	if long.Source != "" {
+		fields = append(fields, "source="+long.Source)
+	}
+	fields = append(fields, "target="+long.Target)
+	if long.ReadOnly {
+		fields = append(fields, "readonly")
+	}
+	if long.Consistency != "" {
+		fields = append(fields, "consistency="+long.Consistency)
+	}
+	if long.Bind != nil && long.Bind.Propagation != "" {
+		fields = append(fields, "bind-propagation="+long.Bind.Propagation)
+	}
+	if long.Volume != nil {
+		if long.Volume.NoCopy {
+			fields = append(fields, "volume-nocopy")
+		}
+		if long.Volume.Subpath != "" {
+			fields = append(fields, "volume-subpath="+long.Volume.Subpath)
+		}
+	}
+	if long.Tmpfs != nil {
+		if long.Tmpfs.Size != "" {
+			fields = append(fields, "tmpfs-size="+string(long.Tmpfs.Size))
+		}
+		if long.Tmpfs.Mode != nil {
+			fields = append(fields, "tmpfs-mode="+strconv.FormatUint(uint64(*long.Tmpfs.Mode), 10))
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+// ComposeServiceVolumeConfig is one entry of the service volumes, either
+// Spec in the short syntax or Long in the long syntax. Short entries are kept
+// as written, so they may hold variables still to be interpolated.
+type ComposeServiceVolumeConfig struct {
+	Spec string
+	Long *VolumeMount
+}
+
+// Mount returns the entry parsed, as a copy for long syntax entries.
+func (v ComposeServiceVolumeConfig) Mount() (*VolumeMount, error) {
+	if v.Long != nil {
+		long := *v.Long
+		return &long, nil
+	}
+	return ParseVolumeMount(v.Spec)
+}
+
+// SetMount replaces the entry with mount, keeping the short syntax when the
+// entry used it and mount can be expressed in it.
+func (v *ComposeServiceVolumeConfig) SetMount(mount *VolumeMount) {
+	if v.Long == nil {
+		if spec, ok := mount.ShortSyntax(); ok {
+			v.Spec = spec
+			return
+		}
+	}
+	v.Spec, v.Long = "", mount.LongSyntax()
+}
+
+func (v ComposeServiceVolumeConfig) String() string {
+	if v.Long != nil {
+		return v.Long.String()
+	}
+	return v.Spec
+}
+
+func (v *ComposeServiceVolumeConfig) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		v.Spec, v.Long = node.Value, nil
+		return nil
+	case yaml.MappingNode:
+		long := &VolumeMount{}
+		if err := node.Decode(long); err != nil {
+			return err
+		}
+		if err := long.validateLong(); err != nil {
+			return newParseError(node, "volumes", "%v", err)
+		}
+		v.Spec, v.Long = "", long
+		return nil
+	}
+	return newParseError(node, "volumes", "invalid volumes format")
+}
+
+func (v ComposeServiceVolumeConfig) MarshalYAML() (any, error) {
+	if v.Long != nil {
+		return v.Long.LongSyntax(), nil
+	}
+	return v.Spec, nil
+}
+
+func (v *ComposeServiceVolumeConfig) UnmarshalJSON(data []byte) error {
+	if err := jsoniter.Unmarshal(data, &v.Spec); err == nil {
+		v.Long = nil
+		return nil
+	}
+	long := &VolumeMount{}
+	if err := jsoniter.Unmarshal(data, long); err != nil {
+		return fmt.Errorf("invalid volumes format: %w", err)
+	}
+	if err := long.validateLong(); err != nil {
+		return fmt.Errorf("invalid volumes format: %w", err)
+	}
+	v.Spec, v.Long = "", long
+	return nil
+}
+
+func (v ComposeServiceVolumeConfig) MarshalJSON() ([]byte, error) {
+	if v.Long != nil {
+		return jsoniter.Marshal(v.Long.LongSyntax())
+	}
+	return jsoniter.Marshal(v.Spec)
+}
+
+type ComposeServiceVolumesConfig []ComposeServiceVolumeConfig
+
+// ParseVolumeMount parses the short volume syntax [SOURCE:]TARGET[:MODE]. A
+// lone target is an anonymous volume.
+func ParseVolumeMount(spec string) (*VolumeMount, error) {
+	if spec == "" {
+		return nil, fmt.Errorf("invalid volume spec: empty")
+	}
+	parts := splitVolumeSpec(spec)
+	mount := &VolumeMount{}
+	switch len(parts) {
+	case 1:
+		mount.Target = parts[0]
+	case 2:
+		mount.Source, mount.Target = parts[0], parts[1]
+	case 3:
+		mount.Source, mount.Target, mount.Mode = parts[0], parts[1], parts[2]
+	default:
+		return nil, fmt.Errorf("invalid volume spec: %s", spec)
+	}
+	if mount.Target == "" {
+		return nil, fmt.Errorf("invalid volume spec: %s", spec)
+	}
+	if isHostPath(mount.Source) {
+		mount.Type = VolumeTypeBind
+	} else {
+		mount.Type = VolumeTypeVolume
+	}
+	return mount, nil
+}
+
+// ValidateVolumeSpec checks a short syntax volume: at most source, target and
+// mode, a non-empty source when one is given, an absolute target and only
+// known mode options.
+func ValidateVolumeSpec(spec string) error {
+	parts := splitVolumeSpec(spec)
+	if len(parts) > 3 {
+		return fmt.Errorf("invalid volume spec %q: too many colon separated parts", spec)
+	}
+	if len(parts) > 1 && parts[0] == "" {
+		return fmt.Errorf("invalid volume spec %q: empty source", spec)
+	}
+	mount, err := ParseVolumeMount(spec)
+	if err != nil {
+		return err
+	}
+	if !isAbsContainerPath(mount.Target) {
+		return fmt.Errorf("invalid volume spec %q: container path %s is not absolute", spec, mount.Target)
+	}
+	if len(parts) == 3 {
+		for _, option := range strings.Split(mount.Mode, ",") {
+			switch {
+			case option == "rw" || option == "ro" || option == "z" || option == "Z" || option == "nocopy":
+			case volumeConsistencies[option] || bindPropagations[option]:
+			default:
+				return fmt.Errorf("invalid volume spec %q: unknown mode %q", spec, option)
+			}
+		}
+	}
+	return nil
+}
+
+// isAbsContainerPath accepts Windows paths too, for Windows containers.
+func isAbsContainerPath(target string) bool {
+	if strings.HasPrefix(target, "/") || strings.HasPrefix(target, `\`) {
+		return true
+	}
+	return len(target) >= 3 && isDriveLetter(target[0]) && target[1] == ':' && (target[2] == '\\' || target[2] == '/')
+}
+
+// splitVolumeSpec splits on colons while keeping Windows drive letters such as
+// C:\data or C:/data attached to their path. A single letter followed by a
+// slash-path is only taken as a drive when a target still follows it, so
+// "c:/data" stays a named volume c mounted at /data.
+func splitVolumeSpec(spec string) []string {
+	parts := strings.Split(spec, ":")
+	result := make([]string, 0, len(parts))
+	for i := 0; i < len(parts); i++ {
+		part := parts[i]
+		if len(part) == 1 && isDriveLetter(part[0]) && i+1 < len(parts) {
+			next := parts[i+1]
+			if strings.HasPrefix(next, `\`) || (strings.HasPrefix(next, "/") && i+2 < len(parts)) {
+				result = append(result, part+":"+next)
+				i++
+				continue
+			}
+		}
+		result = append(result, part)
+	}
+	return result
+}
+
+func isDriveLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isHostPath reports whether a volume source is a host path, including
+// Windows drive paths, UNC paths and MSYS-style //c/data paths.
+func isHostPath(source string) bool {
+	if strings.HasPrefix(source, "/") || strings.HasPrefix(source, ".") || strings.HasPrefix(source, "~") || strings.HasPrefix(source, `\`) {
+		return true
+	}
+	return len(source) >= 3 && isDriveLetter(source[0]) && source[1] == ':' && (source[2] == '\\' || source[2] == '/')
+}
+
+func (serviceConf *ComposeServiceConfig) ParsedVolumes() ([]*VolumeMount, error) {
+	mounts := make([]*VolumeMount, 0, len(serviceConf.Volumes))
+	for _, volume := range serviceConf.Volumes {
+		mount, err := volume.Mount()
+		if err != nil {
+			return nil, err
+		}
+		mounts = append(mounts, mount)
+	}
+	return mounts, nil
+}
+
+// BindMountsToVolumes replaces every bind mount with a named volume called
+// namer(service, source), declaring the volume at the top level. The options
+// only bind mounts take, propagation and the SELinux z and Z, are dropped
+// while ro, nocopy and the consistency are kept. It returns the names of the
+// volumes it declared.
+func (conf *ComposeConfig) BindMountsToVolumes(namer func(service, source string) string) []string {
+	created := []string{}
+	for _, name := range conf.ServiceNames() {
+		serviceConf := (*conf.Services)[name]
+		for i, volume := range serviceConf.Volumes {
+			mount, err := volume.Mount()
+			if err != nil || mount.Type != VolumeTypeBind {
+				continue
+			}
+			volumeName := namer(name, mount.Source)
+			if _, ok := conf.Volumes[volumeName]; !ok {
+				if conf.Volumes == nil {
+					conf.Volumes = map[string]*ComposeVolumeConfig{}
+				}
+				conf.Volumes[volumeName] = &ComposeVolumeConfig{}
+				created = append(created, volumeName)
+			}
+			mount = mount.LongSyntax()
+			mount.Type = VolumeTypeVolume
+			mount.Source = volumeName
+			mount.Bind = nil
+			serviceConf.Volumes[i].SetMount(mount)
+		}
+	}
+	return created
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseVolumeMount(t *testing.T) {
+	tests := []struct {
+		spec string
+		want VolumeMount
+	}{
+		{"/data", VolumeMount{Type: VolumeTypeVolume, Target: "/data"}},
+		{"data:/data", VolumeMount{Type: VolumeTypeVolume, Source: "data", Target: "/data"}},
+		{"./data:/data", VolumeMount{Type: VolumeTypeBind, Source: "./data", Target: "/data"}},
+		{"/srv/data:/data:ro", VolumeMount{Type: VolumeTypeBind, Source: "/srv/data", Target: "/data", Mode: "ro"}},
+		{"/srv/data:/data:rw", VolumeMount{Type: VolumeTypeBind, Source: "/srv/data", Target: "/data", Mode: "rw"}},
+		{"/srv/data:/data:z", VolumeMount{Type: VolumeTypeBind, Source: "/srv/data", Target: "/data", Mode: "z"}},
+		{"/srv/data:/data:Z", VolumeMount{Type: VolumeTypeBind, Source: "/srv/data", Target: "/data", Mode: "Z"}},
+		{"/srv/data:/data:ro,z", VolumeMount{Type: VolumeTypeBind, Source: "/srv/data", Target: "/data", Mode: "ro,z"}},
+		{`C:\data:/data`, VolumeMount{Type: VolumeTypeBind, Source: `C:\data`, Target: "/data"}},
+		{`C:\data:C:\data:ro`, VolumeMount{Type: VolumeTypeBind, Source: `C:\data`, Target: `C:\data`, Mode: "ro"}},
+		{"C:/data:/data", VolumeMount{Type: VolumeTypeBind, Source: "C:/data", Target: "/data"}},
+		{"c:/data", VolumeMount{Type: VolumeTypeVolume, Source: "c", Target: "/data"}},
+	}
+	for _, test := range tests {
+		mount, err := ParseVolumeMount(test.spec)
+		if err != nil {
+			t.Errorf("ParseVolumeMount(%q): %v", test.spec, err)
+			continue
+		}
+		if *mount != test.want {
+			t.Errorf("ParseVolumeMount(%q) = %+v, want %+v", test.spec, *mount, test.want)
+		}
+		if err := ValidateVolumeSpec(test.spec); err != nil {
+			t.Errorf("ValidateVolumeSpec(%q): %v", test.spec, err)
+		}
+	}
+}
+
+func TestValidateVolumeSpecRejects(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"data:",
+		":/data",
+		"data:data",
+		"/srv/data:/data:ro:z",
+		"/srv/data:/data:rx",
+	} {
+		if err := ValidateVolumeSpec(spec); err == nil {
+			t.Errorf("ValidateVolumeSpec(%q) accepted it", spec)
+		}
+	}
+}
+
+func TestTmpfsSize(t *testing.T) {
+	conf := mustParse(t, `services:
+  web:
+    image: nginx
+    volumes:
+      - type: tmpfs
+        target: /cache
+        tmpfs:
+          size: 64m
+`)
+	size, err := (*conf.Services)["web"].Volumes[0].Long.Tmpfs.Size.Bytes()
+	if err != nil || size != 64<<20 {
+		t.Errorf("got %d, %v, want %d", size, err, 64<<20)
+	}
+	_, err = GetConfigFromBytes([]byte(`services:
+  web:
+    image: nginx
+    volumes:
+      - type: tmpfs
+        target: /cache
+        tmpfs:
+          size: lots
+`), FormatYAML)
+	if err == nil || !strings.Contains(err.Error(), "tmpfs.size: invalid byte size") {
+		t.Errorf("got %v, want the invalid tmpfs size", err)
+	}
+}
+
+func TestBindMountsToVolumesDropsBindOptions(t *testing.T) {
+	conf := mustParse(t, `services:
+  web:
+    image: nginx
+    volumes:
+      - ./html:/usr/share/nginx/html:ro,z
+      - ./data:/data:rprivate,nocopy
+      - type: bind
+        source: ./conf
+        target: /etc/nginx
+        bind:
+          propagation: shared
+          selinux: Z
+`)
+	created := conf.BindMountsToVolumes(func(service, source string) string {
+		return service + "-" + strings.TrimPrefix(source, "./")
+	})
+	if len(created) != 3 {
+		t.Fatalf("got %v, want three volumes", created)
+	}
+	var got []string
+	for _, volume := range (*conf.Services)["web"].Volumes {
+		got = append(got, volume.String())
+		if volume.Long != nil && volume.Long.Bind != nil {
+			t.Errorf("%s kept its bind options", volume)
+		}
+	}
+	want := []string{"web-html:/usr/share/nginx/html:ro", "web-data:/data:nocopy", "web-conf:/etc/nginx"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}